@@ -0,0 +1,55 @@
+package access
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"strings"
+)
+
+// clientIDPrefix is the prefix of every client ID, which is followed by the
+// base64 encoding of the raw, uncompressed P-256 public key of the client
+const clientIDPrefix = "WebCrypto-raw.EC.P-256$"
+
+// ErrInvalidClientID is returned when parsing a client ID that does not hold a
+// P-256 public key
+var ErrInvalidClientID = errors.New("invalid client ID")
+
+// ParseClientID gets the public key out of a client ID, of the same format as
+// the one used by the key handshake:
+//
+//	WebCrypto-raw.EC.P-256$<base64 encoded public key>
+func ParseClientID(clientID string) (*ecdsa.PublicKey, error) {
+	encoded, ok := strings.CutPrefix(clientID, clientIDPrefix)
+	if !ok {
+		return nil, ErrInvalidClientID
+	}
+
+	buff, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(buff) != 65 || buff[0] != 4 {
+		return nil, ErrInvalidClientID
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(buff[1:33]),
+		Y:     new(big.Int).SetBytes(buff[33:]),
+	}
+	if !key.Curve.IsOnCurve(key.X, key.Y) {
+		return nil, ErrInvalidClientID
+	}
+
+	return key, nil
+}
+
+// ClientID gets the client ID of the supplied P-256 public key
+func ClientID(key *ecdsa.PublicKey) string {
+	buff := make([]byte, 65)
+	buff[0] = 4
+	key.X.FillBytes(buff[1:33])
+	key.Y.FillBytes(buff[33:])
+
+	return clientIDPrefix + base64.StdEncoding.EncodeToString(buff)
+}
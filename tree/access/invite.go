@@ -0,0 +1,131 @@
+package access
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrInvalidInvite is returned for invites that are malformed, or that have
+	// not been signed by an owner of the tree
+	ErrInvalidInvite = errors.New("invalid invite")
+
+	// ErrInviteExpired is returned for invites that have expired
+	ErrInviteExpired = errors.New("invite has expired")
+
+	// ErrInviteMismatch is returned for invites issued for another tree, or for
+	// another client
+	ErrInviteMismatch = errors.New("invite is for another tree or client")
+)
+
+// Claims is what an invite holds
+type Claims struct {
+	// Tree is the ID of the tree that the invite is for
+	Tree string `json:"tree"`
+
+	// Role is the role that the invited client takes in the tree
+	Role Role `json:"role,omitempty"`
+
+	// Issuer is the client ID of the owner of the tree who signed the invite
+	Issuer string `json:"iss"`
+
+	// Subject is the client ID of the client that the invite is for. Without one,
+	// the invite is for anyone who holds it
+	Subject string `json:"sub,omitempty"`
+
+	// Expires is when the invite expires, in seconds since the Unix epoch
+	Expires int64 `json:"exp"`
+}
+
+// SignInvite issues an invite holding the claims, signed by the supplied key,
+// which is expected to be the key of the issuer.
+//
+// An invite is the unpadded base64url encoding of the JSON claims, followed by
+// a dot, and the unpadded base64url encoding of the signature. The signature is
+// an ECDSA P-256 signature over the SHA-256 hash of the encoded claims, given as
+// the 32 bytes of r followed by the 32 bytes of s, as produced by WebCrypto
+func SignInvite(claims Claims, key *ecdsa.PrivateKey) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+
+	hash := sha256.Sum256([]byte(encoded))
+	r, s, err := ecdsa.Sign(rand.Reader, key, hash[:])
+	if err != nil {
+		return "", err
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// VerifyInvite verifies that the invite has been signed by one of the owners,
+// that it is for the supplied tree and client, and that it has not expired as
+// of now. Returns the claims of the invite
+func VerifyInvite(
+	invite string,
+	treeID string,
+	clientID string,
+	owners []string,
+	now time.Time,
+) (Claims, error) {
+	encoded, encodedSignature, ok := strings.Cut(invite, ".")
+	if !ok {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Claims{}, ErrInvalidInvite
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil || len(signature) != 64 {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	if !slices.Contains(owners, claims.Issuer) {
+		return Claims{}, ErrInvalidInvite
+	}
+	key, err := ParseClientID(claims.Issuer)
+	if err != nil {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	hash := sha256.Sum256([]byte(encoded))
+	r := new(big.Int).SetBytes(signature[:32])
+	s := new(big.Int).SetBytes(signature[32:])
+	if !ecdsa.Verify(key, hash[:], r, s) {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	if claims.Role != "" && !claims.Role.IsValid() {
+		return Claims{}, ErrInvalidInvite
+	}
+
+	if claims.Tree != treeID || (claims.Subject != "" && claims.Subject != clientID) {
+		return Claims{}, ErrInviteMismatch
+	}
+	if !now.Before(time.Unix(claims.Expires, 0)) {
+		return Claims{}, ErrInviteExpired
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,113 @@
+package access
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newKey generates a key, along with the client ID that goes with it
+func newKey(t *testing.T) (*ecdsa.PrivateKey, string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, ClientID(&key.PublicKey)
+}
+
+func TestClientID(t *testing.T) {
+	key, clientID := newKey(t)
+
+	parsed, err := ParseClientID(clientID)
+	if err != nil {
+		t.Fatalf("Expected the client ID to parse, but got %v", err)
+	}
+	if !parsed.Equal(&key.PublicKey) {
+		t.Error("Expected the client ID to hold the public key")
+	}
+
+	for _, invalid := range []string{
+		"",
+		"WebCrypto-raw.EC.P-384$AAAA",
+		"WebCrypto-raw.EC.P-256$not base64",
+		"WebCrypto-raw.EC.P-256$AAAA",
+	} {
+		if _, err := ParseClientID(invalid); !errors.Is(err, ErrInvalidClientID) {
+			t.Errorf("Expected %q to be an invalid client ID, but got %v", invalid, err)
+		}
+	}
+}
+
+func TestVerifyInvite(t *testing.T) {
+	owner, ownerID := newKey(t)
+	stranger, strangerID := newKey(t)
+	_, clientID := newKey(t)
+	now := time.Now()
+
+	claims := Claims{
+		Tree:    "tree",
+		Role:    "viewer",
+		Issuer:  ownerID,
+		Expires: now.Add(time.Minute).Unix(),
+	}
+	sign := func(claims Claims, key *ecdsa.PrivateKey) string {
+		invite, err := SignInvite(claims, key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+
+	invite := sign(claims, owner)
+	got, err := VerifyInvite(invite, "tree", clientID, []string{ownerID}, now)
+	if err != nil {
+		t.Fatalf("Expected the invite to be valid, but got %v", err)
+	}
+	if got != claims {
+		t.Errorf("Expected the claims %v, but got %v", claims, got)
+	}
+
+	expired := claims
+	expired.Expires = now.Add(-time.Second).Unix()
+
+	forSomeoneElse := claims
+	forSomeoneElse.Subject = strangerID
+
+	bySomeoneElse := claims
+	bySomeoneElse.Issuer = strangerID
+
+	// tamper puts the claims of one invite together with the signature of another
+	tamper := func(claimsFrom, signatureFrom string) string {
+		claims, _, _ := strings.Cut(claimsFrom, ".")
+		_, signature, _ := strings.Cut(signatureFrom, ".")
+		return claims + "." + signature
+	}
+
+	tests := []struct {
+		name   string
+		invite string
+		tree   string
+		want   error
+	}{
+		{"expired", sign(expired, owner), "tree", ErrInviteExpired},
+		{"other tree", invite, "other", ErrInviteMismatch},
+		{"other client", sign(forSomeoneElse, owner), "tree", ErrInviteMismatch},
+		{"not an owner", sign(bySomeoneElse, stranger), "tree", ErrInvalidInvite},
+		{"forged", sign(claims, stranger), "tree", ErrInvalidInvite},
+		{"tampered", tamper(sign(forSomeoneElse, owner), invite), "tree", ErrInvalidInvite},
+		{"malformed", "nonsense", "tree", ErrInvalidInvite},
+	}
+
+	for _, test := range tests {
+		_, err := VerifyInvite(test.invite, test.tree, clientID, []string{ownerID}, now)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, but got %v", test.name, test.want, err)
+		}
+	}
+}
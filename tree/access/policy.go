@@ -0,0 +1,159 @@
+package access
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrDenied is returned for clients on the denylist of a tree
+	ErrDenied = errors.New("client is denied access to the tree")
+
+	// ErrInviteRequired is returned for clients that may only join a tree with
+	// an invite, but did not present one
+	ErrInviteRequired = errors.New("an invite is required to join the tree")
+)
+
+// Policy decides who may join a tree, which role they take there, and what of
+// the tree may be seen
+type Policy struct {
+	// Owners are the client IDs of the owners of the tree, who may always join
+	// it, and whose keys sign the invites to it
+	Owners []string `json:"owners"`
+
+	// Allow are the client IDs of the clients that may join the tree without an
+	// invite
+	Allow []string `json:"allow"`
+
+	// Deny are the client IDs of the clients that may never join the tree, not
+	// even with an invite
+	Deny []string `json:"deny"`
+
+	// Roles are the roles of particular clients, by client ID. Invites override
+	// these
+	Roles map[string]Role `json:"roles,omitempty"`
+
+	// DefaultRole is the role of everyone without a role of their own. Owners
+	// default to moderators instead, and everyone defaults to a publisher
+	// without one
+	DefaultRole Role `json:"defaultRole,omitempty"`
+
+	// Watch is how much of the tree those who may join it may watch. Moderators
+	// may always watch everything
+	Watch WatchLevel `json:"watch,omitempty"`
+
+	// Private are the metadata fields that are stripped before the metadata of a
+	// participant reaches its neighbors, or anyone watching the tree without
+	// full access
+	Private []string `json:"private,omitempty"`
+}
+
+// IsOpen determines whether anyone may join the tree, which is the case for
+// trees that have neither owners, nor an allowlist
+func (p Policy) IsOpen() bool {
+	return len(p.Owners) <= 0 && len(p.Allow) <= 0
+}
+
+// isDefault determines whether the policy is no different from having no
+// policy at all
+func (p Policy) isDefault() bool {
+	return p.IsOpen() &&
+		len(p.Deny) <= 0 &&
+		len(p.Roles) <= 0 &&
+		p.DefaultRole == "" &&
+		p.Watch == "" &&
+		len(p.Private) <= 0
+}
+
+// role resolves the role of a client. The role of an invite takes precedence
+// over the role of the client in the policy, which takes precedence over the
+// default
+func (p Policy) role(clientID string, invited Role) Role {
+	if invited != "" {
+		return invited
+	}
+	if role, ok := p.Roles[clientID]; ok {
+		return role
+	}
+	if slices.Contains(p.Owners, clientID) {
+		return RoleModerator
+	}
+	if p.DefaultRole != "" {
+		return p.DefaultRole
+	}
+	return RolePublisher
+}
+
+// Admit decides whether the client may join the tree. Clients on the denylist
+// never may. Owners, clients on the allowlist, and anyone at all in an open
+// tree may join without an invite. Anyone else needs a valid invite.
+//
+// A valid invite grants its role to whoever presents it. An invite that is not
+// valid is only held against clients that need one, so that a stale invite
+// does not lock out a client that may join regardless.
+//
+// Returns the role that the client takes in the tree
+func (p Policy) Admit(treeID, clientID, invite string, now time.Time) (Role, error) {
+	if slices.Contains(p.Deny, clientID) {
+		return "", ErrDenied
+	}
+
+	admitted := p.IsOpen() || slices.Contains(p.Owners, clientID) || slices.Contains(p.Allow, clientID)
+
+	if invite != "" {
+		claims, err := VerifyInvite(invite, treeID, clientID, p.Owners, now)
+		if err == nil {
+			return p.role(clientID, claims.Role), nil
+		}
+		if !admitted {
+			return "", err
+		}
+	}
+
+	if admitted {
+		return p.role(clientID, ""), nil
+	}
+
+	return "", ErrInviteRequired
+}
+
+// Policies holds the policy of every tree. Trees without a policy are open
+type Policies struct {
+	mut      *sync.RWMutex
+	policies map[string]Policy
+}
+
+func NewPolicies() Policies {
+	return Policies{
+		mut:      &sync.RWMutex{},
+		policies: make(map[string]Policy),
+	}
+}
+
+// Get gets the policy of the tree with the supplied ID
+func (p Policies) Get(treeID string) Policy {
+	p.mut.RLock()
+	defer p.mut.RUnlock()
+	return p.policies[treeID]
+}
+
+// Set replaces the policy of the tree with the supplied ID. Clients that have
+// already joined the tree are not affected
+func (p Policies) Set(treeID string, policy Policy) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if policy.isDefault() {
+		delete(p.policies, treeID)
+		return
+	}
+	p.policies[treeID] = policy
+}
+
+// Admit decides whether the client may join the tree with the supplied ID. See
+// Policy.Admit
+func (p Policies) Admit(treeID, clientID, invite string, now time.Time) (Role, error) {
+	return p.Get(treeID).Admit(treeID, clientID, invite, now)
+}
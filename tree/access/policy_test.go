@@ -0,0 +1,163 @@
+package access
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestAdmit(t *testing.T) {
+	owner, ownerID := newKey(t)
+	_, allowedID := newKey(t)
+	_, deniedID := newKey(t)
+	_, clientID := newKey(t)
+	now := time.Now()
+
+	invite := func(clientID string, expires time.Time) string {
+		invite, err := SignInvite(Claims{
+			Tree:    "tree",
+			Role:    RoleRelay,
+			Issuer:  ownerID,
+			Subject: clientID,
+			Expires: expires.Unix(),
+		}, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+	valid := func(clientID string) string { return invite(clientID, now.Add(time.Minute)) }
+	stale := func(clientID string) string { return invite(clientID, now.Add(-time.Minute)) }
+
+	policies := NewPolicies()
+	if role, err := policies.Admit("tree", clientID, "", now); err != nil || role != RolePublisher {
+		t.Errorf("Expected anyone to be admitted into a tree without a policy as a publisher, but got %q, %v", role, err)
+	}
+
+	policies.Set("tree", Policy{
+		Owners: []string{ownerID},
+		Allow:  []string{allowedID},
+		Deny:   []string{deniedID},
+	})
+
+	tests := []struct {
+		name     string
+		clientID string
+		invite   string
+		role     Role
+		want     error
+	}{
+		{"owner", ownerID, "", RoleModerator, nil},
+		{"allowed", allowedID, "", RolePublisher, nil},
+		{"invited", clientID, valid(clientID), RoleRelay, nil},
+		{"uninvited", clientID, "", "", ErrInviteRequired},
+		{"stale invite", clientID, stale(clientID), "", ErrInviteExpired},
+		{"malformed invite", clientID, "garbage", "", ErrInvalidInvite},
+		{"denied", deniedID, "", "", ErrDenied},
+		{"denied despite an invite", deniedID, valid(deniedID), "", ErrDenied},
+		{"invite of someone else", clientID, valid(allowedID), "", ErrInviteMismatch},
+		{"allowed with a valid invite", allowedID, valid(allowedID), RoleRelay, nil},
+		{"owner with a stale invite", ownerID, stale(ownerID), RoleModerator, nil},
+		{"allowed with a malformed invite", allowedID, "garbage", RolePublisher, nil},
+		{"allowed with the invite of someone else", allowedID, valid(clientID), RolePublisher, nil},
+	}
+
+	for _, test := range tests {
+		role, err := policies.Admit("tree", test.clientID, test.invite, now)
+		if !errors.Is(err, test.want) {
+			t.Errorf("%s: expected %v, but got %v", test.name, test.want, err)
+		}
+		if role != test.role {
+			t.Errorf("%s: expected role %q, but got %q", test.name, test.role, role)
+		}
+	}
+
+	// A denylist alone leaves the tree open to everyone else, invites or not,
+	// even though an open tree has no owners to have signed them
+	policies.Set("tree", Policy{Deny: []string{deniedID}})
+	if _, err := policies.Admit("tree", clientID, "", now); err != nil {
+		t.Errorf("Expected a tree with only a denylist to be open, but got %v", err)
+	}
+	for _, invite := range []string{valid(clientID), stale(clientID), "garbage"} {
+		if role, err := policies.Admit("tree", clientID, invite, now); err != nil || role != RolePublisher {
+			t.Errorf("Expected an open tree to admit a client with an invite as a publisher, but got %q, %v", role, err)
+		}
+	}
+	if _, err := policies.Admit("tree", deniedID, "", now); !errors.Is(err, ErrDenied) {
+		t.Errorf("Expected the denylist to apply, but got %v", err)
+	}
+}
+
+func TestRoles(t *testing.T) {
+	owner, ownerID := newKey(t)
+	_, viewerID := newKey(t)
+	_, clientID := newKey(t)
+	_, strangerID := newKey(t)
+	now := time.Now()
+
+	policy := Policy{
+		Owners:      []string{ownerID},
+		Allow:       []string{viewerID, clientID},
+		Roles:       map[string]Role{viewerID: RoleViewer},
+		DefaultRole: RoleRelay,
+	}
+
+	invite := func(role Role) string {
+		invite, err := SignInvite(Claims{
+			Tree:    "tree",
+			Role:    role,
+			Issuer:  ownerID,
+			Expires: now.Add(time.Minute).Unix(),
+		}, owner)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return invite
+	}
+
+	tests := []struct {
+		name     string
+		clientID string
+		invite   string
+		want     Role
+	}{
+		{"owner", ownerID, "", RoleModerator},
+		{"listed", viewerID, "", RoleViewer},
+		{"default", clientID, "", RoleRelay},
+		{"invited", viewerID, invite(RolePublisher), RolePublisher},
+		{"invited without a role", viewerID, invite(""), RoleViewer},
+	}
+
+	for _, test := range tests {
+		role, err := policy.Admit("tree", test.clientID, test.invite, now)
+		if err != nil {
+			t.Errorf("%s: expected to be admitted, but got %v", test.name, err)
+		}
+		if role != test.want {
+			t.Errorf("%s: expected role %q, but got %q", test.name, test.want, role)
+		}
+	}
+
+	if _, err := policy.Admit("tree", strangerID, invite("superuser"), now); !errors.Is(err, ErrInvalidInvite) {
+		t.Errorf("Expected an invite for an unknown role to be invalid, but got %v", err)
+	}
+	if role, err := policy.Admit("tree", clientID, invite("superuser"), now); err != nil || role != RoleRelay {
+		t.Errorf("Expected an invalid invite to leave an allowed client its own role, but got %q, %v", role, err)
+	}
+
+	if !RoleModerator.Can(ActionEditTree) || RolePublisher.Can(ActionEditTree) {
+		t.Error("Expected only moderators to edit the tree")
+	}
+	if !RolePublisher.Can(ActionSource) || RoleModerator.Can(ActionSource) {
+		t.Error("Expected only publishers to claim the source")
+	}
+	if RoleRelay.Can(ActionBroadcast) || !RoleRelay.Can(ActionRelay) {
+		t.Error("Expected relays to only broadcast to their children")
+	}
+	if RoleViewer.Can(ActionSend) || RoleViewer.Can(ActionSendRouted) || !RoleViewer.Can(ActionSendUpstream) {
+		t.Error("Expected viewers to only send to their parent")
+	}
+	if Role("superuser").Can(ActionSendUpstream) {
+		t.Error("Expected unknown roles to be permitted nothing")
+	}
+}
@@ -0,0 +1,70 @@
+package access
+
+import "slices"
+
+// Role is the role that a client takes in a tree, which decides what it may do
+// there
+type Role string
+
+const (
+	// RolePublisher originates what gets sent through the tree, and may claim
+	// the source of the tree
+	RolePublisher Role = "publisher"
+
+	// RoleRelay passes on what it receives to its children
+	RoleRelay Role = "relay"
+
+	// RoleViewer only receives, and only ever talks back to its parent. Viewers
+	// are always leaves
+	RoleViewer Role = "viewer"
+
+	// RoleModerator may do anything a publisher may, other than claim the
+	// source, and may also edit the settings of the tree
+	RoleModerator Role = "moderator"
+)
+
+// Action is something that a participant may or may not be permitted to do
+type Action string
+
+const (
+	// ActionBroadcast is broadcasting to all neighbors
+	ActionBroadcast Action = "broadcast"
+
+	// ActionRelay is broadcasting to children only
+	ActionRelay Action = "relay"
+
+	// ActionSend is sending to any neighbor
+	ActionSend Action = "send"
+
+	// ActionSendUpstream is sending to the parent only, or routing to any
+	// ancestor
+	ActionSendUpstream Action = "send-upstream"
+
+	// ActionSendRouted is routing to anyone in the tree
+	ActionSendRouted Action = "send-routed"
+
+	// ActionEditTree is editing the settings of the tree
+	ActionEditTree Action = "edit-tree"
+
+	// ActionSource is claiming the source of the tree
+	ActionSource Action = "source"
+)
+
+var permissions = map[Role][]Action{
+	RolePublisher: {ActionBroadcast, ActionRelay, ActionSend, ActionSendUpstream, ActionSendRouted, ActionSource},
+	RoleRelay:     {ActionRelay, ActionSend, ActionSendUpstream, ActionSendRouted},
+	RoleViewer:    {ActionSendUpstream},
+	RoleModerator: {ActionBroadcast, ActionRelay, ActionSend, ActionSendUpstream, ActionSendRouted, ActionEditTree},
+}
+
+// IsValid determines whether the role is one of the known roles
+func (r Role) IsValid() bool {
+	_, ok := permissions[r]
+	return ok
+}
+
+// Can determines whether the role permits the action. Unknown roles permit
+// nothing
+func (r Role) Can(action Action) bool {
+	return slices.Contains(permissions[r], action)
+}
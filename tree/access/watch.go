@@ -0,0 +1,92 @@
+package access
+
+import (
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidWatchLevel is returned for watch levels that are not one of the
+// known levels
+var ErrInvalidWatchLevel = errors.New("invalid watch level")
+
+// WatchLevel is how much of a tree a watcher gets to see
+type WatchLevel string
+
+const (
+	// WatchTopology only shows who is connected to whom, without any metadata
+	WatchTopology WatchLevel = "topology"
+
+	// WatchPublic shows the topology, along with the metadata of every
+	// participant, stripped of the tree's private fields
+	WatchPublic WatchLevel = "public"
+
+	// WatchFull shows everything, private fields included
+	WatchFull WatchLevel = "full"
+)
+
+var watchLevels = []WatchLevel{WatchTopology, WatchPublic, WatchFull}
+
+// ParseWatchLevel parses the supplied watch level, failing with
+// ErrInvalidWatchLevel for unknown levels
+func ParseWatchLevel(level string) (WatchLevel, error) {
+	for _, l := range watchLevels {
+		if string(l) == level {
+			return l, nil
+		}
+	}
+	return "", ErrInvalidWatchLevel
+}
+
+// rank orders the watch levels by how much they show. Unknown levels show
+// nothing at all
+func (l WatchLevel) rank() int {
+	for i, level := range watchLevels {
+		if level == l {
+			return i
+		}
+	}
+	return -1
+}
+
+// Includes determines whether the watch level shows at least as much as the
+// supplied level
+func (l WatchLevel) Includes(level WatchLevel) bool {
+	return level.rank() >= 0 && l.rank() >= level.rank()
+}
+
+// WatchLevel gets how much of the tree a client taking the supplied role may
+// watch. Moderators see everything, and everyone else sees what the policy
+// permits, which is the public metadata if unset
+func (p Policy) WatchLevel(role Role) WatchLevel {
+	if role == RoleModerator {
+		return WatchFull
+	}
+	if p.Watch != "" {
+		return p.Watch
+	}
+	return WatchPublic
+}
+
+// Redact strips the policy's private fields from the metadata of a
+// participant. Only top-level fields of metadata that is a JSON object can be
+// private; any other metadata is left as it is
+func (p Policy) Redact(meta json.RawMessage) json.RawMessage {
+	if len(p.Private) <= 0 {
+		return meta
+	}
+
+	var fields map[string]json.RawMessage
+	if json.Unmarshal(meta, &fields) != nil || fields == nil {
+		return meta
+	}
+
+	for _, field := range p.Private {
+		delete(fields, field)
+	}
+
+	redacted, err := json.Marshal(fields)
+	if err != nil {
+		return meta
+	}
+	return redacted
+}
@@ -0,0 +1,47 @@
+package access
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestWatchLevel(t *testing.T) {
+	if _, err := ParseWatchLevel("everything"); !errors.Is(err, ErrInvalidWatchLevel) {
+		t.Errorf("Expected an unknown watch level to be invalid, but got %v", err)
+	}
+
+	if !WatchFull.Includes(WatchPublic) || WatchTopology.Includes(WatchPublic) {
+		t.Error("Expected watch levels to be ordered by how much they show")
+	}
+
+	policy := Policy{Watch: WatchTopology}
+	if level := policy.WatchLevel(RolePublisher); level != WatchTopology {
+		t.Errorf("Expected the policy's watch level, but got %q", level)
+	}
+	if level := policy.WatchLevel(RoleModerator); level != WatchFull {
+		t.Errorf("Expected moderators to see everything, but got %q", level)
+	}
+	if level := (Policy{}).WatchLevel(RoleViewer); level != WatchPublic {
+		t.Errorf("Expected the public metadata by default, but got %q", level)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	policy := Policy{Private: []string{"email", "ip"}}
+
+	redacted := policy.Redact(json.RawMessage(`{"name":"a","email":"a@example.com","ip":"10.0.0.1"}`))
+	var fields map[string]any
+	if err := json.Unmarshal(redacted, &fields); err != nil {
+		t.Fatal(err)
+	}
+	if len(fields) != 1 || fields["name"] != "a" {
+		t.Errorf("Expected only the public fields to be left, but got %s", redacted)
+	}
+
+	for _, meta := range []string{`"email"`, `[1,2]`, `null`} {
+		if redacted := policy.Redact(json.RawMessage(meta)); string(redacted) != meta {
+			t.Errorf("Expected %s to be left as it is, but got %s", meta, redacted)
+		}
+	}
+}
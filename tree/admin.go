@@ -0,0 +1,212 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"tree/access"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager"
+
+	"github.com/gorilla/mux"
+)
+
+// writeAdminError responds to an admin request with an error, shaped the same
+// way as the errors sent over WebSockets
+func writeAdminError(w http.ResponseWriter, status int, errorType, message string) {
+	kind := "CLIENT_ERROR"
+	if status >= 500 {
+		kind = "SERVER_ERROR"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]any{
+		"type": kind,
+		"data": map[string]any{
+			"type": errorType,
+			"data": map[string]any{
+				"message": message,
+			},
+		},
+	})
+}
+
+// writeTreeError responds to an admin request with whichever error the tree
+// manager returned
+func writeTreeError(w http.ResponseWriter, treeID string, err error) {
+	switch {
+	case errors.Is(err, treemanager.ErrTreeExists):
+		writeAdminError(w, http.StatusConflict, "TREE_EXISTS", fmt.Sprintf("Tree %s already exists", treeID))
+	case errors.Is(err, treemanager.ErrTreeNotFound):
+		writeAdminError(w, http.StatusNotFound, "TREE_NOT_FOUND", fmt.Sprintf("Tree %s not found", treeID))
+	case errors.Is(err, treegraph.ErrNodeNotFound):
+		writeAdminError(w, http.StatusNotFound, "PARTICIPANT_NOT_FOUND", err.Error())
+	case errors.Is(err, treegraph.ErrMoveRoot):
+		writeAdminError(w, http.StatusConflict, "CANNOT_MOVE_ROOT", err.Error())
+	case errors.Is(err, treegraph.ErrMoveIntoSubtree):
+		writeAdminError(w, http.StatusConflict, "CANNOT_MOVE_INTO_SUBTREE", err.Error())
+	case errors.Is(err, treegraph.ErrNoRoom):
+		writeAdminError(w, http.StatusConflict, "NO_ROOM", err.Error())
+	default:
+		writeAdminError(w, http.StatusInternalServerError, "UNKNOWN_ERROR", err.Error())
+	}
+}
+
+// requireAdmin only lets through requests that carry the admin token as a
+// bearer token. Without an admin token configured, no requests are let through
+func requireAdmin(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := GetAdminToken()
+		if token == "" {
+			writeAdminError(w, http.StatusForbidden, "ADMIN_DISABLED", "No admin token has been configured")
+			return
+		}
+
+		if !isAdmin(r) {
+			writeAdminError(w, http.StatusUnauthorized, "UNAUTHORIZED", "A valid admin token is required")
+			return
+		}
+
+		handler(w, r)
+	}
+}
+
+// isAdmin determines whether the request carries the admin token as a bearer
+// token. Without an admin token configured, no request does
+func isAdmin(r *http.Request) bool {
+	token := GetAdminToken()
+	if token == "" {
+		return false
+	}
+
+	supplied, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && subtle.ConstantTimeCompare([]byte(supplied), []byte(token)) == 1
+}
+
+// handleCreateTree creates a tree, regardless of the creation policy, with
+// whichever settings the request holds, and the defaults for the rest
+func handleCreateTree(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	var settings treeSettings
+	if err := json.NewDecoder(r.Body).Decode(&settings); err != nil && !errors.Is(err, io.EOF) {
+		writeAdminError(w, http.StatusBadRequest, "MALFORMED_MESSAGE", err.Error())
+		return
+	}
+
+	if err := trees.CreateTree(treeID, settings.apply(trees.DefaultConfig())); err != nil {
+		writeTreeError(w, treeID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+// handleDestroyTree destroys a tree, disconnecting everyone in it
+func handleDestroyTree(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	if err := trees.DestroyTree(treeID); err != nil {
+		writeTreeError(w, treeID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleGetAccess gets who may join a tree
+func handleGetAccess(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(policies.Get(treeID))
+}
+
+// handleSetAccess replaces who may join a tree, which roles they take, and what
+// of the tree may be seen
+func handleSetAccess(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	var policy access.Policy
+	if err := json.NewDecoder(r.Body).Decode(&policy); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "MALFORMED_MESSAGE", err.Error())
+		return
+	}
+
+	for _, clientID := range policy.Owners {
+		if _, err := access.ParseClientID(clientID); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "INVALID_CLIENT_ID", fmt.Sprintf("Owner %s is not a valid client ID", clientID))
+			return
+		}
+	}
+
+	for clientID, role := range policy.Roles {
+		if !role.IsValid() {
+			writeAdminError(w, http.StatusBadRequest, "INVALID_ROLE", fmt.Sprintf("Role %s of %s is not a known role", role, clientID))
+			return
+		}
+	}
+	if policy.DefaultRole != "" && !policy.DefaultRole.IsValid() {
+		writeAdminError(w, http.StatusBadRequest, "INVALID_ROLE", fmt.Sprintf("Default role %s is not a known role", policy.DefaultRole))
+		return
+	}
+
+	if policy.Watch != "" {
+		if _, err := access.ParseWatchLevel(string(policy.Watch)); err != nil {
+			writeAdminError(w, http.StatusBadRequest, "INVALID_WATCH_LEVEL", fmt.Sprintf("Watch level %s is not a known level", policy.Watch))
+			return
+		}
+	}
+
+	policies.Set(treeID, policy)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleMoveNode moves a participant, along with everyone downstream of it,
+// under another participant
+func handleMoveNode(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	var body struct {
+		Node   string `json:"node"`
+		Parent string `json:"parent"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "MALFORMED_MESSAGE", err.Error())
+		return
+	}
+
+	if err := trees.MoveNode(treeID, body.Node, body.Parent); err != nil {
+		writeTreeError(w, treeID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handlePinNode pins a participant in place, or lifts the pin
+func handlePinNode(w http.ResponseWriter, r *http.Request) {
+	treeID := mux.Vars(r)["id"]
+
+	var body struct {
+		Node   string `json:"node"`
+		Pinned bool   `json:"pinned"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAdminError(w, http.StatusBadRequest, "MALFORMED_MESSAGE", err.Error())
+		return
+	}
+
+	if err := trees.PinNode(treeID, body.Node, body.Pinned); err != nil {
+		writeTreeError(w, treeID, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,141 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"errors"
+	"maps"
+	"strings"
+)
+
+var (
+	// ErrUnknownFunction is returned for aggregation functions that are not one
+	// of the known functions
+	ErrUnknownFunction = errors.New("unknown aggregation function")
+
+	// ErrInvalidPayload is returned for payloads that the aggregation function is
+	// unable to aggregate, such as anything other than a number, for sums
+	ErrInvalidPayload = errors.New("payload cannot be aggregated")
+)
+
+// Function is how the payloads of messages get merged
+type Function string
+
+const (
+	// Sum adds up payloads that are numbers
+	Sum Function = "sum"
+
+	// Count counts the messages, whatever their payloads
+	Count Function = "count"
+
+	// Max keeps the largest of payloads that are numbers
+	Max Function = "max"
+
+	// LastPerKey merges payloads that are JSON objects, keeping the last value of
+	// every field
+	LastPerKey Function = "last"
+)
+
+// ParseFunction parses the supplied aggregation function, failing with
+// ErrUnknownFunction for unknown functions
+func ParseFunction(function string) (Function, error) {
+	switch f := Function(function); f {
+	case Sum, Count, Max, LastPerKey:
+		return f, nil
+	}
+	return "", ErrUnknownFunction
+}
+
+// ParseFunctions parses a comma-separated list of message kinds, along with
+// the function that each of them is aggregated with (e.g.
+// "reactions=sum,viewers=count")
+func ParseFunctions(functions string) (map[string]Function, error) {
+	parsed := map[string]Function{}
+	for _, pair := range strings.Split(functions, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+
+		kind, function, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, ErrUnknownFunction
+		}
+		f, err := ParseFunction(strings.TrimSpace(function))
+		if err != nil {
+			return nil, err
+		}
+		parsed[strings.TrimSpace(kind)] = f
+	}
+	return parsed, nil
+}
+
+// Aggregate is the result of merging the payloads of any number of messages
+type Aggregate struct {
+	function Function
+	count    int
+	number   float64
+	fields   map[string]json.RawMessage
+}
+
+// Lift turns the payload of a single message into an aggregate
+func (f Function) Lift(payload json.RawMessage) (Aggregate, error) {
+	a := Aggregate{function: f, count: 1}
+
+	switch f {
+	case Sum, Max:
+		if err := json.Unmarshal(payload, &a.number); err != nil {
+			return Aggregate{}, ErrInvalidPayload
+		}
+	case LastPerKey:
+		if err := json.Unmarshal(payload, &a.fields); err != nil || a.fields == nil {
+			return Aggregate{}, ErrInvalidPayload
+		}
+	case Count:
+	default:
+		return Aggregate{}, ErrUnknownFunction
+	}
+
+	return a, nil
+}
+
+// Function gets the function that the aggregate is the result of
+func (a Aggregate) Function() Function {
+	return a.function
+}
+
+// Count gets the number of messages that the aggregate is made up of
+func (a Aggregate) Count() int {
+	return a.count
+}
+
+// Merge merges the aggregates, with the fields of b taking precedence over
+// those of a, for LastPerKey. Both are expected to have been aggregated by the
+// same function
+func (a Aggregate) Merge(b Aggregate) Aggregate {
+	merged := Aggregate{function: a.function, count: a.count + b.count}
+
+	switch a.function {
+	case Sum:
+		merged.number = a.number + b.number
+	case Max:
+		merged.number = max(a.number, b.number)
+	case LastPerKey:
+		merged.fields = maps.Clone(a.fields)
+		maps.Copy(merged.fields, b.fields)
+	}
+
+	return merged
+}
+
+// Value gets the aggregated value: the total for Sum, the number of messages
+// for Count, the largest number for Max, and the merged object for LastPerKey
+func (a Aggregate) Value() any {
+	switch a.function {
+	case Sum, Max:
+		return a.number
+	case Count:
+		return a.count
+	case LastPerKey:
+		return a.fields
+	}
+	return nil
+}
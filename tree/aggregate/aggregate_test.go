@@ -0,0 +1,84 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+// aggregateAll lifts every payload, and merges them in order
+func aggregateAll(t *testing.T, f Function, payloads ...string) Aggregate {
+	t.Helper()
+
+	var merged Aggregate
+	for i, payload := range payloads {
+		a, err := f.Lift(json.RawMessage(payload))
+		if err != nil {
+			t.Fatalf("Expected %s to be aggregated with %s, but got %v", payload, f, err)
+		}
+		if i == 0 {
+			merged = a
+			continue
+		}
+		merged = merged.Merge(a)
+	}
+	return merged
+}
+
+func TestAggregate(t *testing.T) {
+	tests := []struct {
+		function Function
+		payloads []string
+		want     any
+	}{
+		{Sum, []string{"1", "2.5", "-0.5"}, 3.0},
+		{Count, []string{`"a"`, "null", "{}"}, 3},
+		{Max, []string{"1", "7", "3"}, 7.0},
+		{LastPerKey, []string{`{"a":1,"b":1}`, `{"b":2}`, `{"c":3}`}, map[string]json.RawMessage{
+			"a": json.RawMessage("1"),
+			"b": json.RawMessage("2"),
+			"c": json.RawMessage("3"),
+		}},
+	}
+
+	for _, test := range tests {
+		a := aggregateAll(t, test.function, test.payloads...)
+		if !reflect.DeepEqual(a.Value(), test.want) {
+			t.Errorf("%s: expected %v, but got %v", test.function, test.want, a.Value())
+		}
+		if a.Count() != len(test.payloads) {
+			t.Errorf("%s: expected a count of %d, but got %d", test.function, len(test.payloads), a.Count())
+		}
+	}
+
+	// Merging aggregates that are themselves merged, as happens hop by hop,
+	// is no different from merging everything at once
+	a := aggregateAll(t, Count, "1", "2").Merge(aggregateAll(t, Count, "3", "4", "5"))
+	if a.Value() != 5 {
+		t.Errorf("Expected merged counts to add up, but got %v", a.Value())
+	}
+
+	if _, err := Sum.Lift(json.RawMessage(`"a"`)); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Expected only numbers to be summed, but got %v", err)
+	}
+	if _, err := LastPerKey.Lift(json.RawMessage(`[1]`)); !errors.Is(err, ErrInvalidPayload) {
+		t.Errorf("Expected only objects to be merged, but got %v", err)
+	}
+}
+
+func TestParseFunctions(t *testing.T) {
+	functions, err := ParseFunctions(" reactions=sum, viewers = count,,")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(functions, map[string]Function{"reactions": Sum, "viewers": Count}) {
+		t.Errorf("Expected reactions to be summed and viewers counted, but got %v", functions)
+	}
+
+	for _, invalid := range []string{"reactions", "reactions=average"} {
+		if _, err := ParseFunctions(invalid); !errors.Is(err, ErrUnknownFunction) {
+			t.Errorf("Expected %q to be invalid, but got %v", invalid, err)
+		}
+	}
+}
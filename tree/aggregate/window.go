@@ -0,0 +1,49 @@
+package aggregate
+
+import (
+	"sync"
+	"time"
+)
+
+// Windows merges everything added under the same key within a window of time,
+// and hands on the merged aggregate once the window is over. A window opens
+// with the first aggregate added under a key
+type Windows[K comparable] struct {
+	mut     *sync.Mutex
+	window  time.Duration
+	pending map[K]Aggregate
+	flush   func(K, Aggregate)
+}
+
+// NewWindows creates windows of the supplied length, which hand on their
+// aggregates to flush. Flush is called from a goroutine of its own
+func NewWindows[K comparable](window time.Duration, flush func(K, Aggregate)) Windows[K] {
+	return Windows[K]{
+		mut:     &sync.Mutex{},
+		window:  window,
+		pending: make(map[K]Aggregate),
+		flush:   flush,
+	}
+}
+
+// Add merges the aggregate into the window of the key, opening the window,
+// should it not be open yet
+func (w Windows[K]) Add(key K, a Aggregate) {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if pending, ok := w.pending[key]; ok {
+		w.pending[key] = pending.Merge(a)
+		return
+	}
+
+	w.pending[key] = a
+	time.AfterFunc(w.window, func() {
+		w.mut.Lock()
+		a := w.pending[key]
+		delete(w.pending, key)
+		w.mut.Unlock()
+
+		w.flush(key, a)
+	})
+}
@@ -0,0 +1,48 @@
+package aggregate
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestWindows(t *testing.T) {
+	type flushed struct {
+		key string
+		a   Aggregate
+	}
+	flushes := make(chan flushed, 10)
+	windows := NewWindows(20*time.Millisecond, func(key string, a Aggregate) {
+		flushes <- flushed{key, a}
+	})
+
+	one, _ := Sum.Lift(json.RawMessage("1"))
+	for i := 0; i < 3; i++ {
+		windows.Add("a", one)
+	}
+	windows.Add("b", one)
+
+	got := map[string]Aggregate{}
+	for i := 0; i < 2; i++ {
+		select {
+		case f := <-flushes:
+			got[f.key] = f.a
+		case <-time.After(time.Second):
+			t.Fatal("Expected every window to have been flushed")
+		}
+	}
+	if got["a"].Value() != 3.0 || got["b"].Value() != 1.0 {
+		t.Errorf("Expected a to add up to 3, and b to 1, but got %v and %v", got["a"].Value(), got["b"].Value())
+	}
+
+	// A window that has been flushed opens anew
+	windows.Add("a", one)
+	select {
+	case f := <-flushes:
+		if f.key != "a" || f.a.Count() != 1 {
+			t.Errorf("Expected a new window for a, but got %s with %d", f.key, f.a.Count())
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected the new window to have been flushed")
+	}
+}
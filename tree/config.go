@@ -1,8 +1,16 @@
 package main
 
 import (
+	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
+	"time"
+	"tree/aggregate"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager"
+	"tree/graph/treemanager/listeners"
 )
 
 func GetPort() int {
@@ -18,3 +26,173 @@ func GetPort() int {
 
 	return i
 }
+
+// getIntEnv gets the integer stored in the environment variable of the supplied
+// name, or the fallback, if the variable is absent or not an integer
+func getIntEnv(name string, fallback int) int {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		return fallback
+	}
+
+	return i
+}
+
+// getDurationEnv gets the duration (e.g. "5s") stored in the environment
+// variable of the supplied name, or the fallback, if the variable is absent or
+// not a duration
+func getDurationEnv(name string, fallback time.Duration) time.Duration {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return fallback
+	}
+
+	return d
+}
+
+// getFloatEnv gets the number stored in the environment variable of the
+// supplied name, or the fallback, if the variable is absent or not a number
+func getFloatEnv(name string, fallback float64) float64 {
+	v := os.Getenv(name)
+	if v == "" {
+		return fallback
+	}
+
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		return fallback
+	}
+
+	return f
+}
+
+// GetTreeConfig gets the config that trees are created with, unless they are
+// created with settings of their own
+func GetTreeConfig() treegraph.Config {
+	return treegraph.Config{
+		MaxDegree:  getIntEnv("TREE_MAX_DEGREE", treegraph.DefaultMaxDegree),
+		RootDegree: getIntEnv("TREE_ROOT_DEGREE", treegraph.DefaultRootDegree),
+
+		ChildBandwidth: getIntEnv("TREE_CHILD_BANDWIDTH", 0),
+
+		Placement: os.Getenv("TREE_PLACEMENT"),
+		Seed:      int64(getIntEnv("TREE_PLACEMENT_SEED", 0)),
+		Deletion:  os.Getenv("TREE_DELETION"),
+
+		GracePeriod: getDurationEnv("TREE_GRACE_PERIOD", 0),
+
+		MaxParticipants: getIntEnv("TREE_MAX_PARTICIPANTS", 0),
+
+		Rebalance: treegraph.RebalanceConfig{
+			Interval:      getDurationEnv("TREE_REBALANCE_INTERVAL", 0),
+			Moves:         getIntEnv("TREE_REBALANCE_MOVES", 1),
+			Cooldown:      getDurationEnv("TREE_REBALANCE_COOLDOWN", 0),
+			HeightSlack:   getIntEnv("TREE_REBALANCE_HEIGHT_SLACK", 1),
+			DepthVariance: getFloatEnv("TREE_REBALANCE_DEPTH_VARIANCE", 0),
+		},
+	}
+}
+
+// treeSettings are the settings of a tree that can be supplied as the tree is
+// created, or changed later on. Settings that are absent are left as they are
+type treeSettings struct {
+	MaxDegree       *int `json:"maxDegree"`
+	RootDegree      *int `json:"rootDegree"`
+	MaxParticipants *int `json:"maxParticipants"`
+}
+
+// treeSettingsFromQuery gets the settings in the `maxDegree`, `rootDegree` and
+// `maxParticipants` query parameters
+func treeSettingsFromQuery(query url.Values) (treeSettings, error) {
+	var settings treeSettings
+	for name, setting := range map[string]**int{
+		"maxDegree":       &settings.MaxDegree,
+		"rootDegree":      &settings.RootDegree,
+		"maxParticipants": &settings.MaxParticipants,
+	} {
+		if !query.Has(name) {
+			continue
+		}
+		i, err := strconv.Atoi(query.Get(name))
+		if err != nil {
+			return treeSettings{}, fmt.Errorf("%s is not a number: %w", name, err)
+		}
+		*setting = &i
+	}
+	return settings, nil
+}
+
+// apply gets a copy of the config, with the settings that are present applied
+func (s treeSettings) apply(config treegraph.Config) treegraph.Config {
+	if s.MaxDegree != nil {
+		config.MaxDegree = *s.MaxDegree
+	}
+	if s.RootDegree != nil {
+		config.RootDegree = *s.RootDegree
+	}
+	if s.MaxParticipants != nil {
+		config.MaxParticipants = *s.MaxParticipants
+	}
+	return config
+}
+
+// GetTreeDebug determines whether trees should be validated after every change,
+// which is far too slow for anything other than debugging
+func GetTreeDebug() bool {
+	return os.Getenv("TREE_DEBUG") != ""
+}
+
+// GetAdminToken gets the bearer token that admin requests must carry. Without
+// one, the admin endpoints are disabled
+func GetAdminToken() string {
+	return os.Getenv("ADMIN_TOKEN")
+}
+
+// GetListenerPolicy gets how many changes to a tree may be waiting to be sent to
+// a participant, and what happens to participants that fall further behind
+func GetListenerPolicy() (int, listeners.Policy) {
+	policy := listeners.Policy(os.Getenv("LISTENER_POLICY"))
+	if policy == "" {
+		policy = listeners.PolicyCoalesce
+	}
+	return getIntEnv("LISTENER_QUEUE_SIZE", listeners.DefaultQueueSize), policy
+}
+
+// GetRegistryConfig gets which trees get created by joining them, and for how
+// long trees are kept around without participants
+func GetRegistryConfig() treemanager.RegistryConfig {
+	prefixes := []string{}
+	for _, prefix := range strings.Split(os.Getenv("TREE_CREATION_PREFIXES"), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+
+	return treemanager.RegistryConfig{
+		Creation: treemanager.CreationPolicy(os.Getenv("TREE_CREATION")),
+		Prefixes: prefixes,
+		IdleTTL:  getDurationEnv("TREE_IDLE_TTL", 0),
+	}
+}
+
+// GetUpstreamAggregation gets the kinds of messages sent upstream that get
+// aggregated, along with the function that each of them is aggregated with
+func GetUpstreamAggregation() (map[string]aggregate.Function, error) {
+	return aggregate.ParseFunctions(os.Getenv("UPSTREAM_AGGREGATION"))
+}
+
+// GetUpstreamWindow gets how long messages sent upstream are aggregated for, at
+// every hop
+func GetUpstreamWindow() time.Duration {
+	return getDurationEnv("UPSTREAM_WINDOW", time.Second)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"net/url"
+	"testing"
+
+	"tree/graph/treegraph"
+)
+
+func TestTreeSettings(t *testing.T) {
+	settings, err := treeSettingsFromQuery(url.Values{"maxDegree": {"7"}, "maxParticipants": {"100"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := settings.apply(treegraph.Config{MaxDegree: 3, RootDegree: 4})
+	if config.MaxDegree != 7 || config.RootDegree != 4 || config.MaxParticipants != 100 {
+		t.Errorf("Expected only the supplied settings to be applied, but got %+v", config)
+	}
+
+	if _, err := treeSettingsFromQuery(url.Values{"rootDegree": {"many"}}); err == nil {
+		t.Error("Expected settings that are not numbers to be rejected")
+	}
+}
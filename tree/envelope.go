@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+)
+
+// envelope wraps a message that is relayed from one participant to another,
+// so that the receiver is able to tell who it is from, and to tell it apart
+// from the messages of the server
+type envelope struct {
+	// ID is assigned by the server, and is shared by every copy of the message,
+	// such as those of a broadcast
+	ID string `json:"id"`
+
+	// From is the client ID of the participant that sent the message
+	From string `json:"from"`
+
+	// Timestamp is when the server received the message, in milliseconds since
+	// the Unix epoch
+	Timestamp int64 `json:"timestamp"`
+
+	// Type is the type of the message that the sender sent, such as BROADCAST
+	Type string `json:"type"`
+
+	// Data is the payload of the message, exactly as the sender sent it
+	Data json.RawMessage `json:"data"`
+}
+
+// newEnvelope wraps the message, as it was received from the participant with
+// the supplied client ID
+func newEnvelope(from string, td TypeData) envelope {
+	return envelope{
+		ID:        newMessageID(),
+		From:      from,
+		Timestamp: time.Now().UnixMilli(),
+		Type:      td.Type,
+		Data:      td.Data,
+	}
+}
+
+// newMessageID generates a random ID for a message
+func newMessageID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// deliver sends the relayed message to the participant, in an ENVELOPE message
+// if the participant opted into envelopes, or as the bare payload otherwise
+func (p participant) deliver(e envelope) error {
+	if !p.envelope {
+		return p.writer.WriteJSON(e.Data)
+	}
+	return p.writer.WriteJSON(map[string]any{
+		"type": "ENVELOPE",
+		"data": e,
+	})
+}
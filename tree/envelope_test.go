@@ -0,0 +1,49 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+
+	"tree/access"
+)
+
+func TestEnvelopeOptIn(t *testing.T) {
+	treeID := newChain(t)
+	_, a := joinTree(t, treeID, "a", access.RolePublisher, true)
+	b, _ := joinTree(t, treeID, "b", access.RolePublisher, false)
+	_, c := joinTree(t, treeID, "c", access.RolePublisher, false)
+
+	b.handleMessage(message("BROADCAST", `{"hello":"world"}`))
+
+	// Those who opted in get the message wrapped, along with who it is from
+	enveloped := a.received("ENVELOPE")
+	if len(enveloped) != 1 {
+		t.Fatalf("Expected a to have been sent an envelope, but got %s", a.all())
+	}
+	var e envelope
+	if err := json.Unmarshal(enveloped[0], &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.ID == "" || e.From != "b" || e.Type != "BROADCAST" || e.Timestamp <= 0 {
+		t.Errorf("Expected an envelope of a broadcast from b, but got %+v", e)
+	}
+	if string(e.Data) != `{"hello":"world"}` {
+		t.Errorf("Expected the payload to have been left as it was, but got %s", e.Data)
+	}
+
+	// Everyone else keeps getting the bare payload
+	if received := c.all(); len(received) != 1 || string(received[0]) != `{"hello":"world"}` {
+		t.Errorf("Expected c to have been sent the bare payload, but got %s", received)
+	}
+
+	// Messages sent to a single neighbor are wrapped all the same
+	b.handleMessage(message("SEND", `{"to":"a","data":42}`))
+	enveloped = a.received("ENVELOPE")
+	if len(enveloped) != 2 {
+		t.Fatalf("Expected a to have been sent a second envelope, but got %s", a.all())
+	}
+	json.Unmarshal(enveloped[1], &e)
+	if e.From != "b" || e.Type != "SEND" || string(e.Data) != "42" {
+		t.Errorf("Expected an envelope of 42 sent by b, but got %+v", e)
+	}
+}
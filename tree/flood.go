@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"tree/access"
+	"tree/graph/set"
+)
+
+// floodWindow is how long floods are remembered for, so that a flood that is
+// sent again within it is not delivered twice to anyone
+const floodWindow = time.Minute
+
+// floodKey identifies a flood. Floods are told apart by their sender, so that
+// no one is able to suppress the floods of others by reusing their IDs
+type floodKey struct {
+	treeID string
+	from   string
+	id     string
+}
+
+// delivered is who a flood has been delivered to
+type delivered struct {
+	nodes   set.Set[string]
+	expires time.Time
+}
+
+// deliveries remembers who every recent flood has been delivered to
+type deliveries struct {
+	mut    *sync.Mutex
+	floods map[floodKey]*delivered
+}
+
+func newDeliveries() deliveries {
+	return deliveries{
+		mut:    &sync.Mutex{},
+		floods: make(map[floodKey]*delivered),
+	}
+}
+
+// claim marks the flood as delivered to the node. Returns false, should it
+// already have been
+func (d deliveries) claim(key floodKey, node string, now time.Time) bool {
+	d.mut.Lock()
+	defer d.mut.Unlock()
+
+	flood, ok := d.floods[key]
+	if !ok || !now.Before(flood.expires) {
+		// Forget about the floods that are past their window, while at it
+		for k, f := range d.floods {
+			if !now.Before(f.expires) {
+				delete(d.floods, k)
+			}
+		}
+
+		flood = &delivered{set.Set[string]{}, now.Add(floodWindow)}
+		d.floods[key] = flood
+	}
+
+	if flood.nodes.Has(node) {
+		return false
+	}
+	flood.nodes.Add(node)
+	return true
+}
+
+var floods = newDeliveries()
+
+// floodMessage is what everyone that a flood reaches is sent
+type floodMessage struct {
+	envelope
+
+	// Hops is how many hops away from the sender the receiver is
+	Hops int `json:"hops"`
+
+	// Via is the client ID of the neighbor that the flood reached the receiver
+	// from
+	Via string `json:"via"`
+}
+
+// floodFailure is a participant that a flood could not be delivered to
+type floodFailure struct {
+	To    string `json:"to"`
+	Error string `json:"error"`
+}
+
+// floodReport lets the sender of a flood know who it reached
+type floodReport struct {
+	ID         string         `json:"id"`
+	Delivered  int            `json:"delivered"`
+	Duplicates int            `json:"duplicates"`
+	Failed     []floodFailure `json:"failed"`
+}
+
+// handleFlood spreads the message across the whole tree, along its edges, from
+// the sender outward. Participants that may only relay may only flood their
+// subtree. A flood that reaches no one new, for having reused the ID of an
+// earlier flood within the window, is rejected with a DUPLICATE_FLOOD error
+func (s session) handleFlood(td TypeData) {
+	var m struct {
+		ID      string          `json:"id"`
+		Hops    int             `json:"hops"`
+		Subtree bool            `json:"subtree"`
+		Data    json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(td.Data, &m); err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": "Error parsing the message that was to be flooded",
+			"meta": map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+
+	if !s.role.Can(access.ActionBroadcast) && !(m.Subtree && s.role.Can(access.ActionRelay)) {
+		s.writeForbidden(access.ActionBroadcast, td.Data)
+		return
+	}
+
+	reached, ok := trees.Flood(s.treeID, s.clientID, m.Hops, m.Subtree)
+	if !ok {
+		return
+	}
+
+	e := newEnvelope(s.clientID, TypeData{td.Type, m.Data})
+	if m.ID != "" {
+		e.ID = m.ID
+	}
+
+	now := time.Now()
+	key := floodKey{s.treeID, s.clientID, e.ID}
+	floods.claim(key, s.clientID, now)
+
+	report := floodReport{ID: e.ID, Failed: []floodFailure{}}
+	for _, r := range reached {
+		if !floods.claim(key, r.Key, now) {
+			report.Duplicates++
+			continue
+		}
+
+		// A participant that cannot be reached does not keep the flood from
+		// those beyond it, since it is the server that does the spreading
+		err := r.Value.writer.WriteJSON(map[string]any{
+			"type": "FLOOD",
+			"data": floodMessage{e, r.Hops, r.Via},
+		})
+		if err != nil {
+			report.Failed = append(report.Failed, floodFailure{r.Key, err.Error()})
+			continue
+		}
+		report.Delivered++
+	}
+
+	// Everyone having been reached already means that the ID was reused within
+	// the window, which is most likely a mistake, rather than an attempt at
+	// extending an earlier flood
+	if report.Duplicates > 0 && report.Duplicates == len(reached) {
+		s.writeError("CLIENT_ERROR", "DUPLICATE_FLOOD", map[string]any{
+			"message": fmt.Sprintf("Flood with ID %s already reached everyone it would have reached, within the last %s", e.ID, floodWindow),
+			"meta": map[string]any{
+				"id":               e.ID,
+				"duplicates":       report.Duplicates,
+				"original_message": td.Data,
+			},
+		})
+		return
+	}
+
+	s.writer.WriteJSON(map[string]any{
+		"type": "FLOOD_REPORT",
+		"data": report,
+	})
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"tree/access"
+)
+
+// floodReportOf gets the last FLOOD_REPORT written to the client
+func floodReportOf(t *testing.T, w *fakeWriter) floodReport {
+	t.Helper()
+
+	reports := w.received("FLOOD_REPORT")
+	if len(reports) <= 0 {
+		t.Fatalf("Expected a flood report, but got %s", w.all())
+	}
+	var report floodReport
+	if err := json.Unmarshal(reports[len(reports)-1], &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestFloodDuplicates(t *testing.T) {
+	treeID := newChain(t)
+	a, _ := joinTree(t, treeID, "a", access.RolePublisher, false)
+	bs, b := joinTree(t, treeID, "b", access.RolePublisher, false)
+	_, c := joinTree(t, treeID, "c", access.RoleRelay, false)
+	_, d := joinTree(t, treeID, "d", access.RoleRelay, false)
+
+	a.handleMessage(message("FLOOD", `{"id":"x","hops":1,"data":"hi"}`))
+	report := floodReportOf(t, a.writer.(*fakeWriter))
+	if report.ID != "x" || report.Delivered != 1 || report.Duplicates != 0 {
+		t.Errorf("Expected the flood to have reached b alone, but got %+v", report)
+	}
+
+	// Sending the flood again, further out, only reaches those it has not
+	// reached yet
+	c.err = errors.New("connection closed")
+	a.handleMessage(message("FLOOD", `{"id":"x","hops":3,"data":"hi"}`))
+	report = floodReportOf(t, a.writer.(*fakeWriter))
+	if report.Delivered != 1 || report.Duplicates != 1 {
+		t.Errorf("Expected one delivery and one duplicate, but got %+v", report)
+	}
+	if len(report.Failed) != 1 || report.Failed[0].To != "c" {
+		t.Errorf("Expected the flood to have failed to reach c, but got %+v", report.Failed)
+	}
+	if len(b.received("FLOOD")) != 1 {
+		t.Errorf("Expected b to have been sent the flood once, but got %s", b.all())
+	}
+
+	// A participant that cannot be reached does not keep the flood from those
+	// beyond it
+	var flood floodMessage
+	received := d.received("FLOOD")
+	if len(received) != 1 || json.Unmarshal(received[0], &flood) != nil {
+		t.Fatalf("Expected d to have been sent the flood, but got %s", d.all())
+	}
+	if flood.ID != "x" || flood.Hops != 3 || flood.Via != "c" {
+		t.Errorf("Expected the flood to have reached d via c, 3 hops out, but got %+v", flood)
+	}
+
+	// Floods are told apart by their sender
+	c.err = nil
+	bs.handleMessage(message("FLOOD", `{"id":"x","hops":1,"data":"hi"}`))
+	if report := floodReportOf(t, b); report.Delivered != 2 || report.Duplicates != 0 {
+		t.Errorf("Expected the flood of b to have reached both of its neighbors, but got %+v", report)
+	}
+}
+
+func TestFloodReusedID(t *testing.T) {
+	treeID := newChain(t)
+	a, _ := joinTree(t, treeID, "a", access.RolePublisher, false)
+	_, b := joinTree(t, treeID, "b", access.RoleRelay, false)
+
+	a.handleMessage(message("FLOOD", `{"id":"x","data":"hi"}`))
+	a.handleMessage(message("FLOOD", `{"id":"x","data":"hi again"}`))
+
+	w := a.writer.(*fakeWriter)
+	if reports := w.received("FLOOD_REPORT"); len(reports) != 1 {
+		t.Errorf("Expected only the first flood to have been reported, but got %s", reports)
+	}
+	errs := w.received("CLIENT_ERROR")
+	if len(errs) != 1 || errorType(errs[0]) != "DUPLICATE_FLOOD" {
+		t.Errorf("Expected the reused ID to have been rejected, but got %s", errs)
+	}
+	if len(b.received("FLOOD")) != 1 {
+		t.Errorf("Expected b to have been sent the first flood alone, but got %s", b.all())
+	}
+}
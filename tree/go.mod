@@ -1,6 +1,6 @@
 module tree
 
-go 1.18
+go 1.23
 
 require (
 	github.com/clubcabana/ws-key-auth/go v0.0.0-20230416184158-f1e3b4039526
@@ -1,7 +1,7 @@
 package adjacencylist
 
 import (
-	"fmt"
+	"iter"
 	"tree/graph/maybe"
 	"tree/graph/set"
 )
@@ -122,39 +122,76 @@ func (a AdjacencyList[K, V]) GetAnyKey() maybe.Maybe[K] {
 }
 
 type Pair[K comparable, V any] struct {
-	Key   K
-	Value V
+	Key   K `json:"key"`
+	Value V `json:"value"`
 }
 
-func (a AdjacencyList[K, V]) Traverse(currentNode K, visited set.Set[K]) <-chan Pair[K, V] {
-	c := make(chan Pair[K, V])
+// Walk iterates through all nodes reachable from the current node, on a
+// depth-first-search basis, ensuring to avoid visiting the same node more than
+// once. The walk stops as soon as the caller breaks out of the loop
+func (a AdjacencyList[K, V]) Walk(currentNode K, visited set.Set[K]) iter.Seq[Pair[K, V]] {
+	return func(yield func(Pair[K, V]) bool) {
+		a.walk(currentNode, visited, yield)
+	}
+}
 
-	go func() {
-		defer close(c)
+// walk visits the current node and then its unvisited neighbors, and reports
+// whether the walk should carry on
+func (a AdjacencyList[K, V]) walk(currentNode K, visited set.Set[K], yield func(Pair[K, V]) bool) bool {
+	node, ok := a[currentNode]
+	if !ok {
+		return true
+	}
 
-		node, ok := a[currentNode]
-		if !ok {
-			return
+	visited.Add(currentNode)
+	if !yield(Pair[K, V]{currentNode, node.Value}) {
+		return false
+	}
+
+	// Iterate through each of the keys of the neighboring nodes
+	for neighborKey := range node.Neighbors {
+		if !visited.Has(neighborKey) {
+			if !a.walk(neighborKey, visited, yield) {
+				return false
+			}
 		}
+	}
 
-		visited.Add(currentNode)
-		c <- Pair[K, V]{currentNode, node.Value}
+	return true
+}
 
-		// Iterate through each of the keys of the neighboring nodes
-		for neighborKey := range node.Neighbors {
-			if !visited.Has(neighborKey) {
-				next := a.Traverse(neighborKey, visited)
+// Traverse iterates through all nodes reachable from the current node, on a
+// depth-first-search basis.
+//
+// Deprecated: the channel is fed by a goroutine that is left blocked forever
+// should the caller stop reading early. Use Walk instead
+func (a AdjacencyList[K, V]) Traverse(currentNode K, visited set.Set[K]) <-chan Pair[K, V] {
+	c := make(chan Pair[K, V])
 
-				for pair := range next {
-					c <- pair
-				}
-			}
+	go func() {
+		defer close(c)
+		for pair := range a.Walk(currentNode, visited) {
+			c <- pair
 		}
 	}()
 
 	return c
 }
 
+// GetKeysFromWalk gets the keys of all nodes that the walk visits
+func GetKeysFromWalk[K comparable, V any](walk iter.Seq[Pair[K, V]]) set.Set[K] {
+	s := set.Set[K]{}
+
+	for pair := range walk {
+		s.Add(pair.Key)
+	}
+
+	return s
+}
+
+// GetKeysFromTraversal gets the keys of all nodes that the traversal visits
+//
+// Deprecated: use GetKeysFromWalk instead
 func GetKeysFromTraversal[K comparable, V any](traversal <-chan Pair[K, V]) set.Set[K] {
 	s := set.Set[K]{}
 
@@ -162,7 +199,15 @@ func GetKeysFromTraversal[K comparable, V any](traversal <-chan Pair[K, V]) set.
 		s.Add(pair.Key)
 	}
 
-	fmt.Println("The visited set", s)
-
 	return s
 }
+
+// Map gets a copy of the adjacency list, with every value replaced by whatever
+// the supplied function makes of it
+func Map[K comparable, V, W any](a AdjacencyList[K, V], f func(V) W) AdjacencyList[K, W] {
+	mapped := make(AdjacencyList[K, W], len(a))
+	for key, node := range a {
+		mapped[key] = AdjacencyListNode[K, W]{Value: f(node.Value), Neighbors: node.Neighbors}
+	}
+	return mapped
+}
@@ -0,0 +1,91 @@
+package adjacencylist
+
+import (
+	"reflect"
+	"tree/graph/set"
+)
+
+// Edge is a link from one node to another
+type Edge[K comparable] struct {
+	From K `json:"from"`
+	To   K `json:"to"`
+}
+
+// Difference describes everything that it takes to go from one adjacency list
+// to another.
+//
+// Edges are treated as undirected: a link that goes both ways is reported as a
+// single edge
+type Difference[K comparable, V any] struct {
+	AddedNodes    []Pair[K, V] `json:"addedNodes"`
+	RemovedNodes  []K          `json:"removedNodes"`
+	AddedEdges    []Edge[K]    `json:"addedEdges"`
+	RemovedEdges  []Edge[K]    `json:"removedEdges"`
+	ChangedValues []Pair[K, V] `json:"changedValues"`
+}
+
+// IsEmpty determines whether there is no difference at all
+func (d Difference[K, V]) IsEmpty() bool {
+	return len(d.AddedNodes) == 0 &&
+		len(d.RemovedNodes) == 0 &&
+		len(d.AddedEdges) == 0 &&
+		len(d.RemovedEdges) == 0 &&
+		len(d.ChangedValues) == 0
+}
+
+// Diff gets the difference between the adjacency lists a and b, comparing
+// values via reflect.DeepEqual
+func Diff[K comparable, V any](a, b AdjacencyList[K, V]) Difference[K, V] {
+	return DiffFunc(a, b, func(x, y V) bool { return reflect.DeepEqual(x, y) })
+}
+
+// DiffFunc gets the difference between the adjacency lists a and b, comparing
+// values via the supplied function
+func DiffFunc[K comparable, V any](a, b AdjacencyList[K, V], equal func(V, V) bool) Difference[K, V] {
+	d := Difference[K, V]{
+		AddedNodes:    []Pair[K, V]{},
+		RemovedNodes:  []K{},
+		AddedEdges:    []Edge[K]{},
+		RemovedEdges:  []Edge[K]{},
+		ChangedValues: []Pair[K, V]{},
+	}
+
+	for key, node := range b {
+		old, ok := a[key]
+		if !ok {
+			d.AddedNodes = append(d.AddedNodes, Pair[K, V]{key, node.Value})
+		} else if !equal(old.Value, node.Value) {
+			d.ChangedValues = append(d.ChangedValues, Pair[K, V]{key, node.Value})
+		}
+	}
+
+	for key := range a {
+		if _, ok := b[key]; !ok {
+			d.RemovedNodes = append(d.RemovedNodes, key)
+		}
+	}
+
+	d.AddedEdges = edgesMissingFrom(b, a)
+	d.RemovedEdges = edgesMissingFrom(a, b)
+
+	return d
+}
+
+// edgesMissingFrom gets the edges of a that are absent from b, reporting every
+// edge once, regardless of whether it goes both ways
+func edgesMissingFrom[K comparable, V any](a, b AdjacencyList[K, V]) []Edge[K] {
+	edges := []Edge[K]{}
+	seen := set.Set[Edge[K]]{}
+
+	for key, node := range a {
+		for neighbor := range node.Neighbors.All() {
+			if b[key].Neighbors.Has(neighbor) || seen.Has(Edge[K]{neighbor, key}) {
+				continue
+			}
+			seen.Add(Edge[K]{key, neighbor})
+			edges = append(edges, Edge[K]{key, neighbor})
+		}
+	}
+
+	return edges
+}
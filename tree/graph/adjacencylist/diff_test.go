@@ -0,0 +1,68 @@
+package adjacencylist
+
+import (
+	"encoding/json"
+	"testing"
+	"tree/graph/set"
+)
+
+func valued(edges map[string][]string, values map[string]int) AdjacencyList[string, int] {
+	a := list(edges)
+	for key, value := range values {
+		a[key] = AdjacencyListNode[string, int]{value, a[key].Neighbors}
+	}
+	return a
+}
+
+func TestDiff(t *testing.T) {
+	a := valued(
+		map[string][]string{"a": {"b", "c"}, "b": {"a"}, "c": {"a"}},
+		map[string]int{"a": 1, "b": 2, "c": 3},
+	)
+	b := valued(
+		map[string][]string{"a": {"b"}, "b": {"a", "d"}, "d": {"b"}},
+		map[string]int{"a": 1, "b": 20, "d": 4},
+	)
+
+	d := Diff(a, b)
+
+	if len(d.AddedNodes) != 1 || d.AddedNodes[0] != (Pair[string, int]{"d", 4}) {
+		t.Errorf("Expected d to have been added, but got %v", d.AddedNodes)
+	}
+	if !set.FromSlice(d.RemovedNodes).Equals(set.New("c")) {
+		t.Errorf("Expected c to have been removed, but got %v", d.RemovedNodes)
+	}
+	if len(d.ChangedValues) != 1 || d.ChangedValues[0] != (Pair[string, int]{"b", 20}) {
+		t.Errorf("Expected the value of b to have changed, but got %v", d.ChangedValues)
+	}
+
+	// Every edge is reported once, in either direction
+	if len(d.AddedEdges) != 1 || !set.New(d.AddedEdges[0].From, d.AddedEdges[0].To).Equals(set.New("b", "d")) {
+		t.Errorf("Expected the edge between b and d to have been added, but got %v", d.AddedEdges)
+	}
+	if len(d.RemovedEdges) != 1 || !set.New(d.RemovedEdges[0].From, d.RemovedEdges[0].To).Equals(set.New("a", "c")) {
+		t.Errorf("Expected the edge between a and c to have been removed, but got %v", d.RemovedEdges)
+	}
+
+	if !Diff(a, a).IsEmpty() {
+		t.Errorf("Expected no difference between a list and itself, but got %v", Diff(a, a))
+	}
+}
+
+func TestDiffJSON(t *testing.T) {
+	a := valued(map[string][]string{"a": {}}, map[string]int{"a": 1})
+	b := valued(map[string][]string{"a": {"b"}, "b": {"a"}}, map[string]int{"a": 1, "b": 2})
+
+	encoded, err := json.Marshal(Diff(a, b))
+	if err != nil {
+		t.Fatalf("Expected the diff to be encoded, but got %v", err)
+	}
+
+	var decoded struct {
+		AddedNodes []map[string]any `json:"addedNodes"`
+	}
+	json.Unmarshal(encoded, &decoded)
+	if len(decoded.AddedNodes) != 1 || decoded.AddedNodes[0]["key"] != "b" || decoded.AddedNodes[0]["value"] != 2.0 {
+		t.Errorf("Expected the added node to be encoded in camelCase, but got %s", encoded)
+	}
+}
@@ -0,0 +1,178 @@
+package adjacencylist
+
+import (
+	"fmt"
+	"strings"
+	"tree/graph/set"
+)
+
+// ViolationKind identifies which of the invariants of a tree has been broken
+type ViolationKind string
+
+const (
+	// ViolationDanglingEdge is a node linking to a node that does not exist
+	ViolationDanglingEdge ViolationKind = "dangling-edge"
+
+	// ViolationAsymmetricEdge is a node linking to a neighbor that does not link
+	// back
+	ViolationAsymmetricEdge ViolationKind = "asymmetric-edge"
+
+	// ViolationCycle is an edge that closes a cycle, be it a node linking to
+	// itself, or a node linking to an already reachable node
+	ViolationCycle ViolationKind = "cycle"
+
+	// ViolationDisconnected is a node that is unreachable from the rest of the
+	// graph
+	ViolationDisconnected ViolationKind = "disconnected"
+
+	// ViolationDegree is a node with more neighbors than permitted
+	ViolationDegree ViolationKind = "degree"
+)
+
+// Violation describes a single broken invariant, and where it was found
+type Violation[K comparable] struct {
+	Kind ViolationKind
+
+	// Key is the node that the violation was found at
+	Key K
+
+	// Detail is a human-readable description of the violation
+	Detail string
+}
+
+func (v Violation[K]) String() string {
+	return fmt.Sprintf("%s at %v: %s", v.Kind, v.Key, v.Detail)
+}
+
+// Violations is a list of broken invariants, which doubles as an error
+type Violations[K comparable] []Violation[K]
+
+func (v Violations[K]) Error() string {
+	messages := []string{}
+	for _, violation := range v {
+		messages = append(messages, violation.String())
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Has determines whether any of the violations are of the supplied kind
+func (v Violations[K]) Has(kind ViolationKind) bool {
+	for _, violation := range v {
+		if violation.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks that the adjacency list describes an undirected tree. That
+// is, every edge goes both ways, there are no cycles, every node is reachable
+// from every other node, and no node has more than maxDegree neighbors. A
+// maxDegree of 0 or less leaves the degree of nodes unchecked.
+//
+// An empty list is a valid tree
+func (a AdjacencyList[K, V]) Validate(maxDegree int) Violations[K] {
+	violations := Violations[K]{}
+
+	for key, node := range a {
+		if maxDegree > 0 && len(node.Neighbors) > maxDegree {
+			violations = append(violations, Violation[K]{
+				ViolationDegree,
+				key,
+				fmt.Sprintf("has %d neighbors, but at most %d are permitted", len(node.Neighbors), maxDegree),
+			})
+		}
+
+		for neighbor := range node.Neighbors.All() {
+			other, ok := a[neighbor]
+			switch {
+			case neighbor == key:
+				violations = append(violations, Violation[K]{
+					ViolationCycle,
+					key,
+					"links to itself",
+				})
+			case !ok:
+				violations = append(violations, Violation[K]{
+					ViolationDanglingEdge,
+					key,
+					fmt.Sprintf("links to %v, which does not exist", neighbor),
+				})
+			case !other.Neighbors.Has(key):
+				violations = append(violations, Violation[K]{
+					ViolationAsymmetricEdge,
+					key,
+					fmt.Sprintf("links to %v, which does not link back", neighbor),
+				})
+			}
+		}
+	}
+
+	return append(violations, a.validateShape()...)
+}
+
+// validateShape checks that the graph, with every edge treated as going both
+// ways, is both connected and acyclic
+func (a AdjacencyList[K, V]) validateShape() Violations[K] {
+	violations := Violations[K]{}
+
+	start, ok := a.GetAnyKey().Get()
+	if !ok {
+		return violations
+	}
+
+	undirected := a.Union(a.GetReversed())
+
+	// A depth-first search, keeping track of the node that every node was
+	// reached from, so that the edge back to it is not mistaken for a cycle
+	type step struct {
+		key  K
+		from K
+		root bool
+	}
+	type edge struct{ a, b K }
+	visited := set.Set[K]{}
+	cycles := set.Set[edge]{}
+	stack := []step{{start, start, true}}
+	for len(stack) > 0 {
+		current := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		if visited.Has(current.key) {
+			// The edge closing the cycle is found from both of its ends
+			if cycles.Has(edge{current.key, current.from}) {
+				continue
+			}
+			cycles.Add(edge{current.from, current.key})
+			violations = append(violations, Violation[K]{
+				ViolationCycle,
+				current.key,
+				fmt.Sprintf("is reachable from %v via more than one path", current.from),
+			})
+			continue
+		}
+		visited.Add(current.key)
+
+		for neighbor := range undirected[current.key].Neighbors.All() {
+			if neighbor == current.key || (!current.root && neighbor == current.from) {
+				continue
+			}
+			if _, ok := undirected[neighbor]; !ok {
+				continue
+			}
+			stack = append(stack, step{neighbor, current.key, false})
+		}
+	}
+
+	for key := range a {
+		if !visited.Has(key) {
+			violations = append(violations, Violation[K]{
+				ViolationDisconnected,
+				key,
+				fmt.Sprintf("is unreachable from %v", start),
+			})
+		}
+	}
+
+	return violations
+}
@@ -0,0 +1,54 @@
+package adjacencylist
+
+import (
+	"testing"
+	"tree/graph/set"
+)
+
+func list(edges map[string][]string) AdjacencyList[string, int] {
+	a := AdjacencyList[string, int]{}
+	for key, neighbors := range edges {
+		a[key] = AdjacencyListNode[string, int]{0, set.FromSlice(neighbors)}
+	}
+	return a
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		name     string
+		list     AdjacencyList[string, int]
+		expected []ViolationKind
+	}{
+		{"empty", list(map[string][]string{}), nil},
+		{"tree", list(map[string][]string{"a": {"b", "c"}, "b": {"a"}, "c": {"a"}}), nil},
+		{"asymmetric", list(map[string][]string{"a": {"b"}, "b": {}}), []ViolationKind{ViolationAsymmetricEdge}},
+		{"dangling", list(map[string][]string{"a": {"b"}}), []ViolationKind{ViolationDanglingEdge}},
+		{"self", list(map[string][]string{"a": {"a"}}), []ViolationKind{ViolationCycle}},
+		{"cycle", list(map[string][]string{"a": {"b", "c"}, "b": {"a", "c"}, "c": {"a", "b"}}), []ViolationKind{ViolationCycle}},
+		{"disconnected", list(map[string][]string{"a": {}, "b": {}}), []ViolationKind{ViolationDisconnected}},
+		{"degree", list(map[string][]string{"a": {"b", "c", "d"}, "b": {"a"}, "c": {"a"}, "d": {"a"}}), []ViolationKind{ViolationDegree}},
+	}
+
+	for _, c := range cases {
+		violations := c.list.Validate(2)
+		if len(c.expected) == 0 && len(violations) > 0 {
+			t.Errorf("%s: expected no violations, but got %v", c.name, violations)
+		}
+		for _, kind := range c.expected {
+			if !violations.Has(kind) {
+				t.Errorf("%s: expected a %s violation, but got %v", c.name, kind, violations)
+			}
+		}
+	}
+
+	cycle := list(map[string][]string{"a": {"b", "c"}, "b": {"a", "c"}, "c": {"a", "b"}})
+	count := 0
+	for _, violation := range cycle.Validate(0) {
+		if violation.Kind == ViolationCycle {
+			count++
+		}
+	}
+	if count != 1 {
+		t.Errorf("Expected a single cycle to be reported once, but got %d", count)
+	}
+}
@@ -1,6 +1,7 @@
 package graph
 
 import (
+	"iter"
 	"tree/graph/adjacencylist"
 	"tree/graph/set"
 )
@@ -59,7 +60,7 @@ func (n *Node[K, V]) Interject(newNeighbors []*Node[K, V]) set.Set[K] {
 	s := set.New(n.Key)
 	for _, neighbor := range newNeighbors {
 		s.Add(neighbor.Key)
-		neighbor.Neighbors = append(n.Neighbors, n)
+		neighbor.Neighbors = append(neighbor.Neighbors, n)
 		n.Neighbors = append(n.Neighbors, neighbor)
 	}
 	return s
@@ -94,33 +95,49 @@ func (n Node[K, V]) AdjacencyList(visited set.Set[K]) adjacencylist.AdjacencyLis
 	return list
 }
 
-// Traverse iterates through all nodes in the graph, on a depth-first-search
-// basis, ensuring to avoid traversing the same node more than once
-func (n *Node[K, V]) Traverse(visited set.Set[K]) <-chan *Node[K, V] {
-	visited.Add(n.Key)
+// Walk iterates through all nodes in the graph, on a depth-first-search basis,
+// ensuring to avoid visiting the same node more than once.
+//
+// The walk happens on the caller's goroutine, and stops as soon as the caller
+// breaks out of the loop
+func (n *Node[K, V]) Walk(visited set.Set[K]) iter.Seq[*Node[K, V]] {
+	return func(yield func(*Node[K, V]) bool) {
+		n.walk(visited, yield)
+	}
+}
 
-	c := make(chan *Node[K, V], 3)
+// walk visits the node and then its unvisited neighbors, and reports whether
+// the walk should carry on
+func (n *Node[K, V]) walk(visited set.Set[K], yield func(*Node[K, V]) bool) bool {
+	visited.Add(n.Key)
 
-	go func() {
-		// Emit the current node to the listener
-		c <- n
+	if !yield(n) {
+		return false
+	}
 
-		// Iterate through all the neighbors
-		for _, neighbor := range n.Neighbors {
+	for _, neighbor := range n.Neighbors {
+		if !visited.Has(neighbor.Key) {
+			if !neighbor.walk(visited, yield) {
+				return false
+			}
+		}
+	}
 
-			// Check to see if we have not visited the neighbor yet
-			if !visited.Has(neighbor.Key) {
+	return true
+}
 
-				// If not, add it to our visited list
-				visited.Add(neighbor.Key)
+// Traverse iterates through all nodes in the graph, on a depth-first-search
+// basis, ensuring to avoid traversing the same node more than once.
+//
+// Deprecated: the channel is fed by a goroutine that is left blocked forever
+// should the caller stop reading early. Use Walk instead
+func (n *Node[K, V]) Traverse(visited set.Set[K]) <-chan *Node[K, V] {
+	c := make(chan *Node[K, V], 3)
 
-				// Do a traversal on the neighbouring subtree
-				for child := range neighbor.Traverse(visited) {
-					c <- child
-				}
-			}
+	go func() {
+		for node := range n.Walk(visited) {
+			c <- node
 		}
-
 		close(c)
 	}()
 
@@ -132,7 +149,7 @@ func (n *Node[K, V]) Traverse(visited set.Set[K]) <-chan *Node[K, V] {
 func (n Node[K, V]) ToSlice() []Pair[K, V] {
 	result := []Pair[K, V]{}
 
-	for c := range n.Traverse(set.Set[K]{}) {
+	for c := range n.Walk(set.Set[K]{}) {
 		result = append(result, Pair[K, V]{Key: c.Key, Value: c.Value})
 	}
 
@@ -146,7 +163,7 @@ func (n Node[K, V]) Cardinality() int {
 
 // Find gets the value associated with the supplied key
 func (n Node[K, V]) Find(key K) (*Node[K, V], bool) {
-	for node := range n.Traverse(set.Set[K]{}) {
+	for node := range n.Walk(set.Set[K]{}) {
 		if node.Key == key {
 			return node, true
 		}
@@ -164,7 +181,7 @@ func (n Node[K, V]) Has(key K) bool {
 func (n Node[K, V]) GetMap() map[interface{}]interface{} {
 	m := map[interface{}]interface{}{}
 
-	for c := range n.Traverse(set.Set[K]{}) {
+	for c := range n.Walk(set.Set[K]{}) {
 		m[c.Key] = c.Value
 	}
 
@@ -0,0 +1,77 @@
+package graph
+
+import (
+	"runtime"
+	"testing"
+	"time"
+	"tree/graph/set"
+)
+
+func line(n int) *Node[int, int] {
+	nodes := []*Node[int, int]{}
+	for i := 0; i < n; i++ {
+		nodes = append(nodes, &Node[int, int]{Neighbors: []*Node[int, int]{}, Key: i, Value: i})
+	}
+	for i := 1; i < n; i++ {
+		nodes[i-1].Neighbors = append(nodes[i-1].Neighbors, nodes[i])
+		nodes[i].Neighbors = append(nodes[i].Neighbors, nodes[i-1])
+	}
+	return nodes[0]
+}
+
+func TestWalkStopsEarly(t *testing.T) {
+	visited := set.Set[int]{}
+	count := 0
+	for node := range line(10).Walk(visited) {
+		count++
+		if node.Key == 3 {
+			break
+		}
+	}
+
+	if count != 4 || len(visited) != 4 {
+		t.Errorf("Expected the walk to stop after 4 nodes, but it visited %d", len(visited))
+	}
+}
+
+func TestFindDoesNotLeak(t *testing.T) {
+	head := line(100)
+	before := runtime.NumGoroutine()
+
+	for i := 0; i < 100; i++ {
+		if !head.Has(i) {
+			t.Errorf("Expected %d to be found", i)
+		}
+	}
+
+	// Give any stray goroutines a chance to show up
+	time.Sleep(10 * time.Millisecond)
+
+	if after := runtime.NumGoroutine(); after > before {
+		t.Errorf("Expected no goroutines to be leaked, but %d were", after-before)
+	}
+}
+
+func TestInterjectKeepsNeighbors(t *testing.T) {
+	lone := func(key int) *Node[int, int] {
+		return &Node[int, int]{Neighbors: []*Node[int, int]{}, Key: key, Value: key}
+	}
+	a, b, c, d := lone(0), lone(1), lone(2), lone(3)
+
+	// b already has a neighbor of its own, which it must keep
+	b.Interject([]*Node[int, int]{d})
+
+	modified := a.Interject([]*Node[int, int]{b, c})
+	if !modified.Equals(set.New(0, 1, 2)) {
+		t.Errorf("Expected a, b and c to have been modified, but got %v", modified)
+	}
+	if keys := a.GetNeighborKeys(); !keys.Equals(set.New(1, 2)) || len(a.Neighbors) != 2 {
+		t.Errorf("Expected a to neighbor b and c, but got %v", keys)
+	}
+	if keys := b.GetNeighborKeys(); !keys.Equals(set.New(0, 3)) || len(b.Neighbors) != 2 {
+		t.Errorf("Expected b to neighbor a and d, but got %v", keys)
+	}
+	if keys := c.GetNeighborKeys(); !keys.Equals(set.New(0)) || len(c.Neighbors) != 1 {
+		t.Errorf("Expected c to only neighbor a, but got %v", keys)
+	}
+}
@@ -0,0 +1,224 @@
+package graph
+
+import (
+	"tree/graph/adjacencylist"
+	"tree/graph/set"
+)
+
+// RootedNode represents a node in a rooted tree.
+//
+// Unlike Node, the edges of a rooted tree have an orientation: every node knows
+// which of its neighbors is its parent, and which are its children. The parent
+// is held as a pointer, so that walking towards the root costs O(1) per hop,
+// rather than a traversal of the entire graph
+type RootedNode[K comparable, V any] struct {
+	Parent   *RootedNode[K, V]
+	Children []*RootedNode[K, V]
+	Key      K
+	Value    V
+}
+
+// NewRootedNode creates a lone node, with neither a parent nor children
+func NewRootedNode[K comparable, V any](key K, value V) *RootedNode[K, V] {
+	return &RootedNode[K, V]{
+		Parent:   nil,
+		Children: []*RootedNode[K, V]{},
+		Key:      key,
+		Value:    value,
+	}
+}
+
+// IsRoot determines whether the node is the root of its tree
+func (n *RootedNode[K, V]) IsRoot() bool {
+	return n.Parent == nil
+}
+
+// Root gets the root of the tree that the node is in
+func (n *RootedNode[K, V]) Root() *RootedNode[K, V] {
+	root := n
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	return root
+}
+
+// Depth gets the number of hops between the node and the root
+func (n *RootedNode[K, V]) Depth() int {
+	depth := 0
+	for node := n; node.Parent != nil; node = node.Parent {
+		depth++
+	}
+	return depth
+}
+
+// PathToRoot gets an ordered slice of nodes, starting from the node itself and
+// ending with the root
+func (n *RootedNode[K, V]) PathToRoot() []*RootedNode[K, V] {
+	path := []*RootedNode[K, V]{}
+	for node := n; node != nil; node = node.Parent {
+		path = append(path, node)
+	}
+	return path
+}
+
+// Neighbors gets the parent (if any) and the children of the node, as though
+// the tree was undirected
+func (n *RootedNode[K, V]) Neighbors() []*RootedNode[K, V] {
+	neighbors := []*RootedNode[K, V]{}
+	if n.Parent != nil {
+		neighbors = append(neighbors, n.Parent)
+	}
+	return append(neighbors, n.Children...)
+}
+
+// GetNeighborKeys gets the keys of the parent (if any) and the children of the
+// node
+func (n *RootedNode[K, V]) GetNeighborKeys() set.Set[K] {
+	keys := set.Set[K]{}
+	for _, neighbor := range n.Neighbors() {
+		keys.Add(neighbor.Key)
+	}
+	return keys
+}
+
+// AddChild attaches the supplied node, along with its subtree, as a child of
+// this node. The child is expected to not have a parent
+func (n *RootedNode[K, V]) AddChild(child *RootedNode[K, V]) set.Set[K] {
+	child.Parent = n
+	n.Children = append(n.Children, child)
+	return set.New(n.Key, child.Key)
+}
+
+// Detach removes the node, along with its subtree, from its parent. The
+// returned set holds the keys of the node and its former parent
+func (n *RootedNode[K, V]) Detach() set.Set[K] {
+	modified := set.New(n.Key)
+
+	parent := n.Parent
+	if parent == nil {
+		return modified
+	}
+
+	modified.Add(parent.Key)
+	children := []*RootedNode[K, V]{}
+	for _, child := range parent.Children {
+		if child != n {
+			children = append(children, child)
+		}
+	}
+	parent.Children = children
+	n.Parent = nil
+
+	return modified
+}
+
+// ReplaceWith puts the supplied lone node in this node's place. That is, the
+// replacement inherits both the parent and all the children of this node,
+// leaving this node all by itself. The returned set holds the keys of all
+// nodes that have been modified
+func (n *RootedNode[K, V]) ReplaceWith(replacement *RootedNode[K, V]) set.Set[K] {
+	modified := set.New(n.Key, replacement.Key)
+
+	if n.Parent != nil {
+		modified.Add(n.Parent.Key)
+		for i, child := range n.Parent.Children {
+			if child == n {
+				n.Parent.Children[i] = replacement
+			}
+		}
+	}
+	replacement.Parent = n.Parent
+
+	for _, child := range n.Children {
+		modified.Add(child.Key)
+		child.Parent = replacement
+		replacement.Children = append(replacement.Children, child)
+	}
+
+	n.Parent = nil
+	n.Children = []*RootedNode[K, V]{}
+
+	return modified
+}
+
+// Reroot turns the node into the root of its tree, by flipping the orientation
+// of all edges between the node and the current root. No edges are added or
+// removed. The returned set holds the keys of all nodes whose parent changed
+func (n *RootedNode[K, V]) Reroot() set.Set[K] {
+	modified := set.Set[K]{}
+
+	var previous *RootedNode[K, V]
+	node := n
+	for node != nil {
+		modified.Add(node.Key)
+		parent := node.Parent
+
+		// The node's former parent becomes its child, and the node in turn stops
+		// being a child of the node we have just come from
+		if parent != nil {
+			node.Children = append(node.Children, parent)
+		}
+		if previous != nil {
+			children := []*RootedNode[K, V]{}
+			for _, child := range node.Children {
+				if child != previous {
+					children = append(children, child)
+				}
+			}
+			node.Children = children
+		}
+		node.Parent = previous
+
+		previous = node
+		node = parent
+	}
+
+	return modified
+}
+
+// BreadthFirst gets all nodes in the subtree of this node, in breadth-first
+// order, starting with the node itself
+func (n *RootedNode[K, V]) BreadthFirst() []*RootedNode[K, V] {
+	order := []*RootedNode[K, V]{n}
+	for i := 0; i < len(order); i++ {
+		order = append(order, order[i].Children...)
+	}
+	return order
+}
+
+// Find gets the node with the supplied key, from within the subtree of this
+// node
+func (n *RootedNode[K, V]) Find(key K) (*RootedNode[K, V], bool) {
+	for _, node := range n.BreadthFirst() {
+		if node.Key == key {
+			return node, true
+		}
+	}
+	return nil, false
+}
+
+// AdjacencyList creates an adjacency list of the subtree of this node, as
+// though the tree was undirected
+func (n *RootedNode[K, V]) AdjacencyList() adjacencylist.AdjacencyList[K, V] {
+	list := adjacencylist.AdjacencyList[K, V]{}
+	for _, node := range n.BreadthFirst() {
+		neighbors := node.GetNeighborKeys()
+		if node == n && node.Parent != nil {
+			// The parent is not part of the subtree
+			neighbors = set.FromSlice(keys(node.Children))
+		}
+		list[node.Key] = adjacencylist.AdjacencyListNode[K, V]{
+			Value:     node.Value,
+			Neighbors: neighbors,
+		}
+	}
+	return list
+}
+
+func keys[K comparable, V any](nodes []*RootedNode[K, V]) []K {
+	result := []K{}
+	for _, node := range nodes {
+		result = append(result, node.Key)
+	}
+	return result
+}
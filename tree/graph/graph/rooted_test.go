@@ -0,0 +1,91 @@
+package graph
+
+import (
+	"testing"
+	"tree/graph/set"
+)
+
+// chain creates a chain of nodes, where each node is the parent of the next
+func chain(keys ...string) []*RootedNode[string, int] {
+	nodes := []*RootedNode[string, int]{}
+	for i, key := range keys {
+		node := NewRootedNode(key, i)
+		if i > 0 {
+			nodes[i-1].AddChild(node)
+		}
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+func TestRootedNodeDepth(t *testing.T) {
+	nodes := chain("a", "b", "c", "d")
+
+	for i, node := range nodes {
+		if node.Depth() != i {
+			t.Errorf("Expected %s to have a depth of %d, but got %d", node.Key, i, node.Depth())
+		}
+		if node.Root() != nodes[0] {
+			t.Errorf("Expected the root of %s to be a, but got %s", node.Key, node.Root().Key)
+		}
+	}
+}
+
+func TestRootedNodeDetach(t *testing.T) {
+	nodes := chain("a", "b", "c")
+
+	modified := nodes[1].Detach()
+	if !modified.Equals(set.New("a", "b")) {
+		t.Errorf("Expected a and b to be modified, but got %v", modified)
+	}
+
+	if len(nodes[0].Children) != 0 || !nodes[1].IsRoot() {
+		t.Error("Expected b to no longer be a child of a")
+	}
+
+	if nodes[2].Root() != nodes[1] {
+		t.Error("Expected c to have been detached along with b")
+	}
+}
+
+func TestRootedNodeReplaceWith(t *testing.T) {
+	nodes := chain("a", "b", "c")
+	d := NewRootedNode("d", 3)
+	nodes[1].AddChild(d)
+
+	nodes[2].Detach()
+	modified := nodes[1].ReplaceWith(nodes[2])
+
+	if !modified.Equals(set.New("a", "b", "c", "d")) {
+		t.Errorf("Expected a, b, c and d to be modified, but got %v", modified)
+	}
+
+	if nodes[2].Parent != nodes[0] || d.Parent != nodes[2] {
+		t.Error("Expected c to have taken b's place")
+	}
+
+	if !nodes[1].IsRoot() || len(nodes[1].Children) != 0 {
+		t.Error("Expected b to have been left all by itself")
+	}
+}
+
+func TestRootedNodeReroot(t *testing.T) {
+	nodes := chain("a", "b", "c")
+	d := NewRootedNode("d", 3)
+	nodes[0].AddChild(d)
+
+	before := nodes[0].AdjacencyList()
+
+	modified := nodes[2].Reroot()
+	if !modified.Equals(set.New("a", "b", "c")) {
+		t.Errorf("Expected a, b and c to be modified, but got %v", modified)
+	}
+
+	if !nodes[2].IsRoot() || d.Depth() != 3 {
+		t.Error("Expected c to be the root, and d to be the deepest node")
+	}
+
+	if !nodes[2].AdjacencyList().Equal(before) {
+		t.Error("Expected rerooting to leave the edges of the tree untouched")
+	}
+}
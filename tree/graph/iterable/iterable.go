@@ -1,10 +1,21 @@
 package iterable
 
+import "iter"
+
 // Iterable represents a type that can be iterated on
+//
+// Deprecated: use SeqIterable instead, which does not need a goroutine to
+// iterate
 type Iterable[V any] interface {
 	Iterate() <-chan V
 }
 
+// SeqIterable represents a type that can be iterated on via a range-over-func
+// iterator
+type SeqIterable[V any] interface {
+	All() iter.Seq[V]
+}
+
 // ToSlice takes an iterable, and converts it into a slice
 func ToSlice[V any](i Iterable[V]) []interface{} {
 	result := []interface{}{}
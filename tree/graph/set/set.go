@@ -1,12 +1,16 @@
 package set
 
-import "tree/graph/iterable"
+import (
+	"iter"
+	"tree/graph/iterable"
+)
 
 // Set is for representing a set of objects, irrespective insertion order.
 // additionally, duplicate insertion of the same key into a Set will result in
 // subsequent `Add` invocations to effectively be a no-op
 type Set[K comparable] map[K]bool
 
+var _ iterable.SeqIterable[int] = Set[int]{}
 var _ iterable.Iterable[int] = Set[int]{}
 
 func New[K comparable](items ...K) Set[K] {
@@ -39,11 +43,25 @@ func (s Set[K]) Has(value K) bool {
 	return s[value]
 }
 
+// All iterates through the keys of the set, in no particular order
+func (s Set[K]) All() iter.Seq[K] {
+	return func(yield func(K) bool) {
+		for k, ok := range s {
+			if ok && !yield(k) {
+				return
+			}
+		}
+	}
+}
+
 // Iterate creates a channel purely for iteration purposes
+//
+// Deprecated: the channel is fed by a goroutine that is left blocked forever
+// should the caller stop reading early. Use All instead
 func (s Set[K]) Iterate() <-chan K {
 	c := make(chan K)
 	go func() {
-		for k := range s {
+		for k := range s.All() {
 			c <- k
 		}
 		close(c)
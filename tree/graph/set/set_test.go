@@ -11,3 +11,24 @@ func TestHas(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestAll(t *testing.T) {
+	s := New(1, 2, 3)
+
+	seen := New[int]()
+	for k := range s.All() {
+		seen.Add(k)
+	}
+	if !seen.Equals(s) {
+		t.Errorf("Expected to iterate through %v, but got %v", s, seen)
+	}
+
+	count := 0
+	for range s.All() {
+		count++
+		break
+	}
+	if count != 1 {
+		t.Errorf("Expected the iteration to stop after 1 key, but got %d", count)
+	}
+}
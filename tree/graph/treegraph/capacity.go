@@ -0,0 +1,43 @@
+package treegraph
+
+import "tree/graph/maybe"
+
+// Capacity is the upload capacity that a node has declared for itself
+type Capacity struct {
+	// Children is the number of children that the node is able to relay to
+	Children maybe.Maybe[int]
+
+	// Bandwidth is the upload bandwidth of the node, in bits per second. It is
+	// only taken into account if the node did not declare a number of children,
+	// and if the tree has been configured with a per-child bandwidth
+	Bandwidth maybe.Maybe[int]
+}
+
+// CapacityDeclarer is implemented by node values that are able to declare how
+// many children the node is able to take on.
+//
+// Values that do not implement this interface are assumed to be able to take
+// on as many children as the tree's config permits
+type CapacityDeclarer interface {
+	DeclaredCapacity() Capacity
+}
+
+// children gets the number of children that the capacity allows for, given the
+// tree's config
+func (c Capacity) children(config Config) maybe.Maybe[int] {
+	if children, ok := c.Children.Get(); ok {
+		if children < 0 {
+			return maybe.Something(0)
+		}
+		return maybe.Something(children)
+	}
+
+	if bandwidth, ok := c.Bandwidth.Get(); ok && config.ChildBandwidth > 0 {
+		if bandwidth < 0 {
+			return maybe.Something(0)
+		}
+		return maybe.Something(bandwidth / config.ChildBandwidth)
+	}
+
+	return maybe.Nothing[int]()
+}
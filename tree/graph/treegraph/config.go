@@ -0,0 +1,151 @@
+package treegraph
+
+import "time"
+
+const (
+	// DefaultMaxDegree is the maximum number of neighbors that a non-root node
+	// will be given, when no other configuration has been supplied
+	DefaultMaxDegree = 3
+
+	// DefaultRootDegree is the maximum number of neighbors that the root node
+	// will be given, when no other configuration has been supplied
+	DefaultRootDegree = 3
+)
+
+// Config describes the shape of a tree.
+//
+// Degrees are expressed in terms of neighbors, and for all nodes other than the
+// root, the link to the parent counts towards the degree. That is, a node with a
+// max degree of 3 will relay to at most 2 children
+type Config struct {
+	// MaxDegree is the maximum number of neighbors that any node other than the
+	// root may have. Values smaller than 2 are treated as 2, since anything less
+	// would not allow the tree to grow past the root's children
+	MaxDegree int
+
+	// RootDegree is the maximum number of neighbors that the root may have.
+	// Values smaller than 1 are treated as 1
+	RootDegree int
+
+	// ChildBandwidth is the upload bandwidth, in bits per second, that a node
+	// needs for every child that it relays to. It is used to turn a node's
+	// declared bandwidth into a number of children. A value of 0 means that
+	// declared bandwidths are ignored
+	ChildBandwidth int
+
+	// Placement is the name of the placement strategy that decides where nodes
+	// go. See the Placement* constants. Unknown names fall back to
+	// PlacementShortestSubtree
+	Placement string
+
+	// Seed is the seed used by placement strategies that involve randomness
+	Seed int64
+
+	// Deletion is the name of the mode by which the tree gets repaired, after a
+	// node has been deleted. See the Deletion* constants. Unknown names fall
+	// back to DeletionReplace
+	Deletion string
+
+	// GracePeriod is how long a node that has lost its connection is held in its
+	// place as suspended, before it gets deleted. A grace period of 0 means that
+	// such nodes get deleted right away. It is up to whatever is managing the
+	// tree to enforce it
+	GracePeriod time.Duration
+
+	// MaxParticipants is the maximum number of nodes that the tree may hold. A
+	// value of 0 means that there is no limit. It is up to whatever is managing
+	// the tree to enforce it
+	MaxParticipants int
+
+	// Rebalance governs the rebalancing of the tree, as it gets lopsided through
+	// churn. See RebalanceConfig
+	Rebalance RebalanceConfig
+}
+
+// RebalanceConfig governs when and how much a tree gets rebalanced.
+//
+// Rebalancing is done in small steps, by moving the deepest leaves to wherever
+// there is a shallower spot with room to spare, so that every step only
+// disturbs a handful of nodes
+type RebalanceConfig struct {
+	// Interval is how often the tree gets checked for whether it needs
+	// rebalancing. An interval of 0 turns rebalancing off. It is up to whatever
+	// is managing the tree to enforce it
+	Interval time.Duration
+
+	// Moves is the maximum number of nodes that get moved per interval. Values
+	// smaller than 1 are treated as 1
+	Moves int
+
+	// Cooldown is how long a node that has just been moved is left alone, before
+	// it may be moved again. It is up to whatever is managing the tree to
+	// enforce it
+	Cooldown time.Duration
+
+	// HeightSlack is how many levels taller than the shallowest possible tree of
+	// the same size the tree may grow, before it needs rebalancing. Values
+	// smaller than 0 are treated as 0
+	HeightSlack int
+
+	// DepthVariance is how large the variance of the depth of the nodes may
+	// grow, before the tree needs rebalancing. A value of 0 leaves the variance
+	// unchecked
+	DepthVariance float64
+}
+
+// DefaultConfig gets the configuration that trees are created with, when no
+// other configuration has been supplied
+func DefaultConfig() Config {
+	return Config{
+		MaxDegree:  DefaultMaxDegree,
+		RootDegree: DefaultRootDegree,
+		Placement:  PlacementShortestSubtree,
+		Deletion:   DeletionReplace,
+	}
+}
+
+// normalized gets a copy of the config with all values clamped to something
+// that the tree is able to work with
+func (c Config) normalized() Config {
+	if c.MaxDegree < 2 {
+		c.MaxDegree = 2
+	}
+	if c.RootDegree < 1 {
+		c.RootDegree = 1
+	}
+	if c.ChildBandwidth < 0 {
+		c.ChildBandwidth = 0
+	}
+	switch c.Placement {
+	case PlacementShortestSubtree, PlacementBreadthFirst, PlacementRandom:
+	default:
+		c.Placement = PlacementShortestSubtree
+	}
+	if c.GracePeriod < 0 {
+		c.GracePeriod = 0
+	}
+	if c.MaxParticipants < 0 {
+		c.MaxParticipants = 0
+	}
+	if c.Rebalance.Interval < 0 {
+		c.Rebalance.Interval = 0
+	}
+	if c.Rebalance.Moves < 1 {
+		c.Rebalance.Moves = 1
+	}
+	if c.Rebalance.Cooldown < 0 {
+		c.Rebalance.Cooldown = 0
+	}
+	if c.Rebalance.HeightSlack < 0 {
+		c.Rebalance.HeightSlack = 0
+	}
+	if c.Rebalance.DepthVariance < 0 {
+		c.Rebalance.DepthVariance = 0
+	}
+	switch c.Deletion {
+	case DeletionReplace, DeletionPromote, DeletionReattach:
+	default:
+		c.Deletion = DeletionReplace
+	}
+	return c
+}
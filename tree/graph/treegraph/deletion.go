@@ -0,0 +1,206 @@
+package treegraph
+
+import (
+	"sort"
+	"tree/graph/graph"
+	"tree/graph/maybe"
+	"tree/graph/set"
+)
+
+const (
+	// DeletionReplace has the placement strategy pick a node to take the
+	// deleted node's place. With the default strategy, that is the deepest leaf
+	// of the deleted node's subtree
+	DeletionReplace = "replace"
+
+	// DeletionPromote promotes one of the deleted node's own children into the
+	// deleted node's place. Whichever of the siblings the promoted child is
+	// unable to hold get reattached to the nearest node with room to spare, or
+	// evicted, should there be none
+	DeletionPromote = "promote"
+
+	// DeletionReattach reattaches each of the deleted node's subtrees to the
+	// nearest node with room to spare, starting from the deleted node's parent,
+	// and evicts those that there is no room for
+	DeletionReattach = "reattach"
+)
+
+// DeletionStats keeps a tally of how disruptive the deletions in a tree have
+// been, so that deletion modes can be compared against each other
+type DeletionStats struct {
+	// Deletions is the number of nodes that have been deleted
+	Deletions int
+
+	// Modified is the number of nodes, other than the deleted nodes themselves,
+	// that have been modified by deletions
+	Modified int
+}
+
+// ModifiedPerDeletion gets the average number of nodes modified by a deletion
+func (s DeletionStats) ModifiedPerDeletion() float64 {
+	if s.Deletions <= 0 {
+		return 0
+	}
+	return float64(s.Modified) / float64(s.Deletions)
+}
+
+// DeletionStats gets the tally of how disruptive the deletions in the tree have
+// been so far
+func (t Tree[K, V]) DeletionStats() DeletionStats {
+	return t.stats
+}
+
+// recordDeletion adds the outcome of a deletion to the tally
+func (t *Tree[K, V]) recordDeletion(key K, modified set.Set[K]) {
+	t.stats.Deletions++
+	t.stats.Modified += len(modified)
+	if modified.Has(key) {
+		t.stats.Modified--
+	}
+}
+
+// promote puts one of the node's children in the node's place.
+//
+// The child with the most room to spare is picked, so that as few of its
+// siblings as possible need to go elsewhere. Pinned children are only promoted,
+// or moved elsewhere, should there be no other children to pick
+func (t *Tree[K, V]) promote(node *graph.RootedNode[K, V]) set.Set[K] {
+	if len(node.Children) <= 0 {
+		return t.removeLeaf(node)
+	}
+
+	sizes := subtreeSizes(node.Children)
+	children := append([]*graph.RootedNode[K, V]{}, node.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		if pi, pj := t.IsPinned(children[i].Key), t.IsPinned(children[j].Key); pi != pj {
+			return pj
+		}
+		a := t.ChildLimit(children[i]) - len(children[i].Children)
+		b := t.ChildLimit(children[j]) - len(children[j].Children)
+		if a != b {
+			return a > b
+		}
+		return sizes[children[i].Key] > sizes[children[j].Key]
+	})
+	promoted := children[0]
+	siblings := children[1:]
+
+	// Everything in the promoted child's subtree is now one hop closer to the
+	// root
+	modified := promoted.Detach().Union(t.subtreeKeys(promoted))
+	modified = modified.Union(node.ReplaceWith(promoted))
+	if root, _ := t.maybeRoot.Get(); root == node {
+		t.maybeRoot = maybe.Something(promoted)
+	}
+
+	// Move the smallest of the siblings away, should the promoted child be
+	// unable to hold all of them
+	sort.SliceStable(siblings, func(i, j int) bool {
+		if pi, pj := t.IsPinned(siblings[i].Key), t.IsPinned(siblings[j].Key); pi != pj {
+			return pj
+		}
+		return sizes[siblings[i].Key] < sizes[siblings[j].Key]
+	})
+	excess := len(promoted.Children) - t.ChildLimit(promoted)
+	for i := 0; i < excess && i < len(siblings); i++ {
+		modified = modified.Union(siblings[i].Detach())
+		modified = modified.Union(t.placeNear(promoted, siblings[i]))
+	}
+
+	// Should it have taken over as the root, the promoted child may very well
+	// be permitted fewer children than it already had of its own
+	return modified.Union(t.trimNear(promoted))
+}
+
+// reattach removes the node from the tree, and attaches each of its subtrees to
+// the nearest node with room to spare.
+//
+// Should the node be the root, its largest subtree takes over as the root
+func (t *Tree[K, V]) reattach(node *graph.RootedNode[K, V]) set.Set[K] {
+	if len(node.Children) <= 0 {
+		return t.removeLeaf(node)
+	}
+
+	sizes := subtreeSizes(node.Children)
+	orphans := append([]*graph.RootedNode[K, V]{}, node.Children...)
+	sort.SliceStable(orphans, func(i, j int) bool {
+		return sizes[orphans[i].Key] > sizes[orphans[j].Key]
+	})
+
+	origin := node.Parent
+	modified := node.Detach()
+	for _, orphan := range orphans {
+		modified = modified.Union(orphan.Detach())
+	}
+
+	if origin == nil {
+		t.maybeRoot = maybe.Something(orphans[0])
+		modified = modified.Union(t.subtreeKeys(orphans[0]))
+		origin = orphans[0]
+		orphans = orphans[1:]
+
+		// The root may very well be permitted fewer children than the new root
+		// already has
+		modified = modified.Union(t.trimNear(origin))
+	}
+
+	for _, orphan := range orphans {
+		modified = modified.Union(t.placeNear(origin, orphan))
+	}
+
+	return modified
+}
+
+// removeLeaf removes a node that has no children from the tree
+func (t *Tree[K, V]) removeLeaf(node *graph.RootedNode[K, V]) set.Set[K] {
+	if root, _ := t.maybeRoot.Get(); root == node {
+		t.maybeRoot = maybe.Nothing[*graph.RootedNode[K, V]]()
+	}
+	return node.Detach()
+}
+
+// trimNear moves the children that the node has beyond what it is permitted to
+// have, as picked by ExcessChildren, to the nodes with room to spare nearest to
+// the node. Returns the set of all nodes that were modified
+func (t *Tree[K, V]) trimNear(node *graph.RootedNode[K, V]) set.Set[K] {
+	modified := set.Set[K]{}
+	for _, child := range ExcessChildren(t, node) {
+		modified = modified.Union(child.Detach())
+		modified = modified.Union(t.placeNear(node, child))
+	}
+	return modified
+}
+
+// placeNear attaches the detached subtree to the node with room to spare that
+// is the fewest hops away from the origin, evicting the subtree should there be
+// no such node. Returns the set of all nodes that were modified
+func (t *Tree[K, V]) placeNear(origin, subtree *graph.RootedNode[K, V]) set.Set[K] {
+	order := []*graph.RootedNode[K, V]{origin}
+	visited := set.New(origin.Key)
+	for i := 0; i < len(order); i++ {
+		for _, neighbor := range order[i].Neighbors() {
+			if !visited.Has(neighbor.Key) {
+				visited.Add(neighbor.Key)
+				order = append(order, neighbor)
+			}
+		}
+	}
+
+	for _, node := range order {
+		if t.HasRoom(node) {
+			return t.attach(node, subtree)
+		}
+	}
+
+	t.evict(subtree)
+	return set.Set[K]{}
+}
+
+// subtreeSizes gets the number of nodes in the subtree of each of the nodes
+func subtreeSizes[K comparable, V any](nodes []*graph.RootedNode[K, V]) map[K]int {
+	sizes := map[K]int{}
+	for _, node := range nodes {
+		sizes[node.Key] = len(node.BreadthFirst())
+	}
+	return sizes
+}
@@ -0,0 +1,157 @@
+package treegraph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"tree/graph/set"
+)
+
+var deletions = []string{DeletionReplace, DeletionPromote, DeletionReattach}
+
+// churn builds up a tree, and then randomly deletes and inserts nodes, calling
+// check after every deletion with the tree's adjacency list from before and
+// after the deletion
+func churn(
+	tree *Tree[string, int],
+	seed int64,
+	check func(key string, modified set.Set[string], before, after map[string]set.Set[string]),
+) {
+	r := rand.New(rand.NewSource(seed))
+
+	neighbors := func() map[string]set.Set[string] {
+		m := map[string]set.Set[string]{}
+		for key, node := range tree.AdjacencyList() {
+			m[key] = node.Neighbors
+		}
+		return m
+	}
+
+	for i := 0; i < 100; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	for i := 100; i < 400; i++ {
+		key := fmt.Sprint(r.Intn(i))
+		if !tree.Has(key) {
+			continue
+		}
+
+		before := neighbors()
+		modified := tree.DeleteByKey(key)
+		check(key, modified, before, neighbors())
+
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+}
+
+func TestDeletionModesKeepTree(t *testing.T) {
+	for _, deletion := range deletions {
+		t.Run(deletion, func(t *testing.T) {
+			config := Config{MaxDegree: 3, RootDegree: 3, Deletion: deletion}
+			tree := New[string, int](config)
+
+			churn(&tree, 1, func(key string, _ set.Set[string], _, after map[string]set.Set[string]) {
+				if _, ok := after[key]; ok {
+					t.Fatalf("Expected %s to have been deleted", key)
+				}
+			})
+
+			list := tree.AdjacencyList()
+			root, _ := tree.Root().Get()
+			checkDegrees(t, list, root.Key, config)
+
+			if !IsTree(list) {
+				t.Error("Expected the graph to be a tree, but ended up not being a tree")
+			}
+		})
+	}
+}
+
+func TestDeletionModesReportModified(t *testing.T) {
+	for _, deletion := range deletions {
+		t.Run(deletion, func(t *testing.T) {
+			tree := New[string, int](Config{MaxDegree: 3, RootDegree: 3, Deletion: deletion})
+
+			churn(&tree, 2, func(key string, modified set.Set[string], before, after map[string]set.Set[string]) {
+				for k, neighbors := range after {
+					changed := !neighbors.Equals(before[k])
+					if changed && !modified.Has(k) {
+						t.Errorf("Deleting %s changed the neighbors of %s, but it was not reported", key, k)
+					}
+					if !changed && modified.Has(k) {
+						t.Errorf("Deleting %s did not change the neighbors of %s, but it was reported", key, k)
+					}
+				}
+			})
+		})
+	}
+}
+
+func TestDeletionPromote(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 4, RootDegree: 1, Deletion: DeletionPromote})
+	tree.SetSource("a")
+
+	// a -> b -> c, d, e
+	for _, key := range []string{"a", "b", "c", "d", "e"} {
+		tree.Upsert(key, 0)
+	}
+
+	modified := tree.DeleteByKey("b")
+	if !modified.Equals(set.New("a", "b", "c", "d", "e")) {
+		t.Errorf("Expected only a, b and its children to be modified, but got %v", modified)
+	}
+
+	position, _ := tree.GetPositionOfNode("a")
+	if len(position.Children) != 1 {
+		t.Fatalf("Expected a to have exactly one child, but got %v", position.Children)
+	}
+
+	promoted := position.Children[0].Key
+	position, _ = tree.GetPositionOfNode(promoted)
+	if len(position.Children) != 2 || position.Depth != 1 {
+		t.Errorf("Expected %s to have taken b's place, but got %v", promoted, position)
+	}
+}
+
+func TestDeletionNarrowRoot(t *testing.T) {
+	for _, deletion := range deletions {
+		t.Run(deletion, func(t *testing.T) {
+			tree := New[string, int](Config{MaxDegree: 4, RootDegree: 1, Deletion: deletion})
+
+			// a -> b -> c, d, e
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				tree.Upsert(key, 0)
+			}
+
+			// Whoever takes over as the root is permitted fewer children than b has
+			tree.DeleteByKey("a")
+			if violations := tree.Validate(); len(violations) > 0 {
+				t.Errorf("Expected no violations, but got %v", violations)
+			}
+			if evicted := tree.TakeEvicted(); len(evicted) != 0 {
+				t.Errorf("Expected nobody to have been evicted, but got %v", evicted)
+			}
+			if tree.Len() != 4 {
+				t.Errorf("Expected 4 nodes to be left, but got %v", tree.AdjacencyList())
+			}
+		})
+	}
+}
+
+func BenchmarkDeletionModes(b *testing.B) {
+	for _, deletion := range deletions {
+		b.Run(deletion, func(b *testing.B) {
+			var stats DeletionStats
+			for i := 0; i < b.N; i++ {
+				tree := New[string, int](Config{MaxDegree: 3, RootDegree: 3, Deletion: deletion})
+				churn(&tree, int64(i), func(string, set.Set[string], map[string]set.Set[string], map[string]set.Set[string]) {})
+
+				s := tree.DeletionStats()
+				stats.Deletions += s.Deletions
+				stats.Modified += s.Modified
+			}
+			b.ReportMetric(stats.ModifiedPerDeletion(), "modified/delete")
+		})
+	}
+}
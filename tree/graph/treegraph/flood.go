@@ -0,0 +1,62 @@
+package treegraph
+
+import "tree/graph/graph"
+
+// Reached is a node that a flood reaches
+type Reached[K comparable, V any] struct {
+	Pair[K, V]
+
+	// Hops is the number of edges between the node and the origin of the flood
+	Hops int
+
+	// Via is the key of the neighbor that the flood reached the node from
+	Via K
+}
+
+// Flood gets every node that a flood from the node with the supplied key
+// reaches, spreading along the edges of the tree, from the origin outward. The
+// origin itself is not included. A flood with a hop limit greater than 0 goes
+// no further than that many hops from the origin. A flood that is restricted
+// to the subtree of the origin only spreads downstream.
+//
+// Every node is reached once, and in order of how many hops away from the
+// origin it is
+func (t Tree[K, V]) Flood(origin K, maxHops int, subtree bool) ([]Reached[K, V], bool) {
+	node, ok := t.find(origin)
+	if !ok {
+		return nil, false
+	}
+
+	type step struct {
+		node *graph.RootedNode[K, V]
+		hops int
+	}
+
+	reached := []Reached[K, V]{}
+	visited := map[K]bool{origin: true}
+	queue := []step{{node, 0}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+
+		if maxHops > 0 && current.hops >= maxHops {
+			continue
+		}
+
+		next := current.node.Neighbors()
+		if subtree {
+			next = current.node.Children
+		}
+		for _, neighbor := range next {
+			if visited[neighbor.Key] {
+				continue
+			}
+			visited[neighbor.Key] = true
+
+			reached = append(reached, Reached[K, V]{t.toPair(neighbor), current.hops + 1, current.node.Key})
+			queue = append(queue, step{neighbor, current.hops + 1})
+		}
+	}
+
+	return reached, true
+}
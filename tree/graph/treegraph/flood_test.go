@@ -0,0 +1,54 @@
+package treegraph
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFlood(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 2})
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		tree.Upsert(key, i)
+	}
+
+	// a has b and c as its children, b has d, and c has e
+	tests := []struct {
+		name    string
+		origin  string
+		maxHops int
+		subtree bool
+		want    []string
+	}{
+		{"everyone", "d", 0, false, []string{"b", "a", "c", "e"}},
+		{"hop limit", "d", 2, false, []string{"b", "a"}},
+		{"subtree", "c", 0, true, []string{"e"}},
+		{"subtree of the root", "a", 0, true, []string{"b", "c", "d", "e"}},
+		{"leaf subtree", "e", 0, true, []string{}},
+	}
+
+	for _, test := range tests {
+		reached, ok := tree.Flood(test.origin, test.maxHops, test.subtree)
+		if !ok {
+			t.Fatalf("%s: expected %s to be in the tree", test.name, test.origin)
+		}
+
+		keys := []string{}
+		for _, r := range reached {
+			keys = append(keys, r.Key)
+		}
+		if !slices.Equal(keys, test.want) {
+			t.Errorf("%s: expected the flood to reach %v, but got %v", test.name, test.want, keys)
+		}
+	}
+
+	reached, _ := tree.Flood("d", 0, false)
+	for _, r := range reached {
+		if r.Key == "e" && (r.Hops != 4 || r.Via != "c") {
+			t.Errorf("Expected e to be reached in 4 hops via c, but got %d hops via %s", r.Hops, r.Via)
+		}
+	}
+
+	if _, ok := tree.Flood("z", 0, false); ok {
+		t.Error("Expected no flood from a node that is not in the tree")
+	}
+}
@@ -0,0 +1,79 @@
+package treegraph
+
+import (
+	"fmt"
+	"testing"
+	"tree/graph/graph"
+	"tree/graph/maybe"
+	"tree/graph/set"
+)
+
+func TestIndexFollowsTree(t *testing.T) {
+	for _, deletion := range []string{DeletionReplace, DeletionPromote, DeletionReattach} {
+		tree := New[string, int](Config{MaxDegree: 3, RootDegree: 2, Deletion: deletion})
+		churn(&tree, 7, func(string, set.Set[string], map[string]set.Set[string], map[string]set.Set[string]) {
+			list := tree.AdjacencyList()
+			if len(list) != len(tree.nodes) {
+				t.Errorf("%s: Expected %d nodes in the index, but got %d", deletion, len(list), len(tree.nodes))
+			}
+			for key := range list {
+				node, ok := tree.find(key)
+				if !ok || node.Key != key {
+					t.Errorf("%s: Expected %s to be indexed", deletion, key)
+				}
+			}
+		})
+
+		tree.SetSource("99")
+		tree.Upsert("99", 99)
+		if root, _ := tree.Root().Get(); root != tree.nodes["99"] {
+			t.Errorf("%s: Expected the source to be indexed as the root", deletion)
+		}
+	}
+}
+
+// balancedTree builds a tree of n nodes by wiring them up directly, rather than
+// via Upsert, which has to search the tree for a spot on every insert
+func balancedTree(n int) (Tree[string, int], []string) {
+	tree := New[string, int](DefaultConfig())
+	keys := []string{}
+	order := []*graph.RootedNode[string, int]{}
+	for i := 0; i < n; i++ {
+		key := fmt.Sprint(i)
+		node := graph.NewRootedNode(key, i)
+		if i == 0 {
+			tree.maybeRoot = maybe.Something(node)
+		} else {
+			parent := order[(i-1)/2]
+			parent.AddChild(node)
+		}
+		tree.index(node)
+		order = append(order, node)
+		keys = append(keys, key)
+	}
+	return tree, keys
+}
+
+func BenchmarkLookup(b *testing.B) {
+	for _, n := range []int{10000, 100000} {
+		tree, keys := balancedTree(n)
+
+		b.Run(fmt.Sprintf("Find/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.Find(keys[i%n])
+			}
+		})
+
+		b.Run(fmt.Sprintf("Has/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.Has(keys[i%n])
+			}
+		})
+
+		b.Run(fmt.Sprintf("GetNeighborsOfNode/%d", n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				tree.GetNeighborsOfNode(keys[i%n])
+			}
+		})
+	}
+}
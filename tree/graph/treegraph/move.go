@@ -0,0 +1,108 @@
+package treegraph
+
+import (
+	"errors"
+	"tree/graph/set"
+)
+
+var (
+	// ErrNodeNotFound is returned when a node that is to be moved or pinned, or
+	// that is to be moved under, is not in the tree
+	ErrNodeNotFound = errors.New("node not found")
+
+	// ErrMoveRoot is returned when attempting to move the root, which has no
+	// parent to be moved away from
+	ErrMoveRoot = errors.New("the root cannot be moved")
+
+	// ErrMoveIntoSubtree is returned when attempting to move a node under itself,
+	// or under any node in its subtree
+	ErrMoveIntoSubtree = errors.New("a node cannot be moved into its own subtree")
+
+	// ErrNoRoom is returned when attempting to move a node under a node that
+	// has no room for another child. See HasRoom
+	ErrNoRoom = errors.New("the new parent has no room for another child")
+)
+
+// Move makes the node with the supplied key, along with its subtree, a child
+// of the new parent, regardless of what the placement strategy would have
+// picked, and regardless of whether the node is pinned. The new parent must
+// have room for another child, both as far as the config and as far as the
+// capacity that the new parent declared for itself are concerned, and must not
+// be suspended. See HasRoom.
+//
+// The returned set holds the keys of all nodes whose neighbors have changed
+func (t *Tree[K, V]) Move(key, newParent K) (set.Set[K], error) {
+	node, ok := t.find(key)
+	if !ok {
+		return set.Set[K]{}, ErrNodeNotFound
+	}
+
+	parent, ok := t.find(newParent)
+	if !ok {
+		return set.Set[K]{}, ErrNodeNotFound
+	}
+
+	if node.IsRoot() {
+		return set.Set[K]{}, ErrMoveRoot
+	}
+
+	if node.Parent == parent {
+		return set.Set[K]{}, nil
+	}
+
+	for _, ancestor := range parent.PathToRoot() {
+		if ancestor == node {
+			return set.Set[K]{}, ErrMoveIntoSubtree
+		}
+	}
+
+	if !t.HasRoom(parent) {
+		return set.Set[K]{}, ErrNoRoom
+	}
+
+	return node.Detach().Union(t.attach(parent, node)), nil
+}
+
+// Pin marks the node with the supplied key as pinned.
+//
+// Whenever the tree has a choice in which nodes to move, be it while making
+// room, repairing the tree after a deletion, or rebalancing, it moves nodes
+// other than pinned ones. A pinned node only ever gets moved when there is no
+// other way around it, such as when its parent leaves, or via Move.
+//
+// The returned set holds the key of the node, should it not have been pinned
+// already
+func (t *Tree[K, V]) Pin(key K) (set.Set[K], error) {
+	if !t.Has(key) {
+		return set.Set[K]{}, ErrNodeNotFound
+	}
+	if t.IsPinned(key) {
+		return set.Set[K]{}, nil
+	}
+
+	if t.pinned == nil {
+		t.pinned = set.Set[K]{}
+	}
+	t.pinned.Add(key)
+
+	return set.New(key), nil
+}
+
+// Unpin lifts the pin from the node with the supplied key. The returned set
+// holds the key of the node, should it have been pinned
+func (t *Tree[K, V]) Unpin(key K) (set.Set[K], error) {
+	if !t.Has(key) {
+		return set.Set[K]{}, ErrNodeNotFound
+	}
+	if !t.IsPinned(key) {
+		return set.Set[K]{}, nil
+	}
+
+	delete(t.pinned, key)
+	return set.New(key), nil
+}
+
+// IsPinned determines whether the node with the supplied key is pinned
+func (t Tree[K, V]) IsPinned(key K) bool {
+	return t.pinned.Has(key)
+}
@@ -0,0 +1,126 @@
+package treegraph
+
+import (
+	"errors"
+	"testing"
+	"tree/graph/set"
+)
+
+func TestMove(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 2})
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		tree.Upsert(key, i)
+	}
+
+	// a has b and c as its children, and b has d and e
+	position, _ := tree.GetPositionOfNode("d")
+	if parent, _ := position.Parent.Get(); parent.Key != "b" {
+		t.Fatalf("Expected d to be under b, but got %s", parent.Key)
+	}
+
+	modified, err := tree.Move("d", "c")
+	if err != nil {
+		t.Fatalf("Expected d to be moved, but got %v", err)
+	}
+	if !modified.Equals(set.New("b", "c", "d")) {
+		t.Errorf("Expected b, c and d to be modified, but got %v", modified)
+	}
+	position, _ = tree.GetPositionOfNode("d")
+	if parent, _ := position.Parent.Get(); parent.Key != "c" {
+		t.Errorf("Expected d to be under c, but got %s", parent.Key)
+	}
+
+	if violations := tree.Validate(); len(violations) > 0 {
+		t.Errorf("Expected the tree to still be valid, but got %v", violations)
+	}
+
+	cases := []struct {
+		key, parent string
+		expected    error
+	}{
+		{"x", "a", ErrNodeNotFound},
+		{"d", "x", ErrNodeNotFound},
+		{"a", "d", ErrMoveRoot},
+		{"c", "d", ErrMoveIntoSubtree},
+		{"c", "c", ErrMoveIntoSubtree},
+		{"e", "a", ErrNoRoom},
+	}
+	for _, c := range cases {
+		if _, err := tree.Move(c.key, c.parent); !errors.Is(err, c.expected) {
+			t.Errorf("Expected moving %s under %s to fail with %v, but got %v", c.key, c.parent, c.expected, err)
+		}
+	}
+}
+
+func TestMoveRespectsRoom(t *testing.T) {
+	tree := New[string, capacityValue](Config{MaxDegree: 4, RootDegree: 2})
+	for _, key := range []string{"a", "b", "c", "d"} {
+		tree.Upsert(key, capacityValue{-1})
+	}
+
+	// a has b and c as its children, and b has d
+	tree.Upsert("c", capacityValue{0})
+	if _, err := tree.Move("d", "c"); !errors.Is(err, ErrNoRoom) {
+		t.Errorf("Expected moving under a node that declared no room to fail, but got %v", err)
+	}
+
+	tree.Upsert("c", capacityValue{-1})
+	tree.Suspend("c")
+	if _, err := tree.Move("d", "c"); !errors.Is(err, ErrNoRoom) {
+		t.Errorf("Expected moving under a suspended node to fail, but got %v", err)
+	}
+
+	tree.Resume("c")
+	if _, err := tree.Move("d", "c"); err != nil {
+		t.Errorf("Expected d to be moved under c, but got %v", err)
+	}
+}
+
+func TestPinnedNodesStayPut(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 4, RootDegree: 3})
+	for i, key := range []string{"a", "b", "c", "d"} {
+		tree.Upsert(key, i)
+	}
+	if modified, err := tree.Pin("d"); err != nil || !modified.Equals(set.New("d")) {
+		t.Fatalf("Expected d to have been pinned, but got %v, %v", modified, err)
+	}
+	if modified, _ := tree.Pin("d"); len(modified) != 0 {
+		t.Errorf("Expected pinning d again to change nothing, but got %v", modified)
+	}
+
+	// a has to give up two of its three children, and d is the one to keep
+	tree.SetConfig(Config{MaxDegree: 4, RootDegree: 1})
+	position, _ := tree.GetPositionOfNode("d")
+	if parent, _ := position.Parent.Get(); parent.Key != "a" {
+		t.Errorf("Expected the pinned d to have stayed under a, but got %s", parent.Key)
+	}
+
+	// The pinned d is not to be picked as a replacement for a
+	tree.DeleteByKey("a")
+	if _, ok := tree.GetPositionOfNode("d"); !ok {
+		t.Fatal("Expected d to still be in the tree")
+	}
+	if root, _ := tree.Root().Get(); root.Key == "d" {
+		t.Error("Expected the pinned d to not have replaced a")
+	}
+
+	// With every leaf pinned, the orphans of the deleted b take over instead
+	tree = New[string, int](Config{MaxDegree: 3, RootDegree: 1})
+	for i, key := range []string{"a", "b", "c", "d"} {
+		tree.Upsert(key, i)
+	}
+	tree.Pin("c")
+	tree.Pin("d")
+	tree.DeleteByKey("a")
+	if root, _ := tree.Root().Get(); root.Key != "b" {
+		t.Errorf("Expected b to have taken over from a, but got %s", root.Key)
+	}
+
+	if modified, _ := tree.Unpin("d"); tree.IsPinned("d") || !modified.Equals(set.New("d")) {
+		t.Errorf("Expected d to have been unpinned, but got %v", modified)
+	}
+
+	if _, err := tree.Pin("x"); !errors.Is(err, ErrNodeNotFound) {
+		t.Errorf("Expected pinning a missing node to fail, but got %v", err)
+	}
+}
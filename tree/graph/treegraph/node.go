@@ -26,7 +26,9 @@ func (n *Node[K, V]) Upsert(
 		return set.New(key)
 	}
 
-	maybeSubTree := n.ShortestSubTree(set.Set[K]{})
+	// Only descend away from the nodes that we have already visited, otherwise
+	// the shortest subtree may very well be the one that we came from
+	maybeSubTree := n.ShortestSubTree(visited)
 
 	subTree, ok := maybeSubTree.Get()
 
@@ -96,7 +98,7 @@ func (n *Node[K, V]) GetLeafiestNode(visited set.Set[K]) *Node[K, V] {
 // trees
 func (n *Node[K, V]) CleaveLeafiestNode(visited set.Set[K]) (*Node[K, V], set.Set[K]) {
 	leaf := n.GetLeafiestNode(visited)
-	_, modified := (*graph.Node[K, V])(n).Cleave()
+	_, modified := (*graph.Node[K, V])(leaf).Cleave()
 	return leaf, modified
 }
 
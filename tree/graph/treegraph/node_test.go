@@ -11,8 +11,8 @@ import (
 )
 
 func compareTree[K comparable, V comparable](t *testing.T, m map[K]V, node *Node[K, V]) {
-	treeChan := (*graph.Node[K, V])(node).Traverse(set.Set[K]{})
-	for pair := range treeChan {
+	walk := (*graph.Node[K, V])(node).Walk(set.Set[K]{})
+	for pair := range walk {
 		key, value := pair.Key, pair.Value
 		if v, ok := m[key]; ok {
 			if v != value {
@@ -138,6 +138,7 @@ func TestIterate(t *testing.T) {
 
 	m := map[string]int{}
 
+	// The deprecated channel traversal is kept working for existing callers
 	for node := range (*graph.Node[string, int])(&tree).Traverse(set.Set[string]{}) {
 		m[node.Key] = node.Value
 	}
@@ -200,6 +201,35 @@ func TestDelete(t *testing.T) {
 	compareTree(t, expectedPairs, tree)
 }
 
+func TestCleaveLeafiestNode(t *testing.T) {
+	a := &Node[string, int]{[]*graph.Node[string, int]{}, "a", 1}
+	b := &graph.Node[string, int]{Neighbors: []*graph.Node[string, int]{}, Key: "b", Value: 2}
+	c := &graph.Node[string, int]{Neighbors: []*graph.Node[string, int]{}, Key: "c", Value: 3}
+
+	// a - b - c
+	(*graph.Node[string, int])(a).Interject([]*graph.Node[string, int]{b})
+	b.Interject([]*graph.Node[string, int]{c})
+
+	leaf, modified := a.CleaveLeafiestNode(set.Set[string]{})
+	if leaf.Key != "c" {
+		t.Fatalf("Expected c to be the leafiest node, but got %s", leaf.Key)
+	}
+	if !modified.Equals(set.New("b", "c")) {
+		t.Errorf("Expected only b and c to have been modified, but got %v", modified)
+	}
+
+	// Only the leaf is cut off; the rest of the tree stays as it was
+	if len(c.Neighbors) != 0 {
+		t.Errorf("Expected c to have no neighbors left, but got %v", c.GetNeighborKeys())
+	}
+	if keys := b.GetNeighborKeys(); !keys.Equals(set.New("a")) {
+		t.Errorf("Expected b to only neighbor a, but got %v", keys)
+	}
+	if keys := (*graph.Node[string, int])(a).GetNeighborKeys(); !keys.Equals(set.New("b")) {
+		t.Errorf("Expected a to still neighbor b, but got %v", keys)
+	}
+}
+
 func TestTreeEmpty(t *testing.T) {
 	maybeTree := maybe.Something(&Node[string, int]{[]*graph.Node[string, int]{}, "cool", 1})
 
@@ -385,7 +415,7 @@ func IsGraphConnected[K comparable, V any](list adjacencylist.AdjacencyList[K, V
 		return false
 	}
 
-	traversalKeys := adjacencylist.GetKeysFromTraversal(list.Union(list.GetReversed()).Traverse(key, set.Set[K]{}))
+	traversalKeys := adjacencylist.GetKeysFromWalk(list.Union(list.GetReversed()).Walk(key, set.Set[K]{}))
 	return traversalKeys.Equals(list.GetKeys())
 }
 
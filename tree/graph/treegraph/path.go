@@ -0,0 +1,66 @@
+package treegraph
+
+import (
+	"slices"
+	"tree/graph/graph"
+)
+
+// Path gets the nodes along the path from the node with the key from, to the
+// node with the key to, both of them included. The path goes up from the first
+// node to the nearest ancestor that the two nodes share, and back down to the
+// second node
+func (t Tree[K, V]) Path(from, to K) ([]Pair[K, V], bool) {
+	start, ok := t.find(from)
+	if !ok {
+		return nil, false
+	}
+	end, ok := t.find(to)
+	if !ok {
+		return nil, false
+	}
+
+	up := []*graph.RootedNode[K, V]{}
+	ancestors := map[K]int{}
+	for node := start; node != nil; node = node.Parent {
+		ancestors[node.Key] = len(up)
+		up = append(up, node)
+	}
+
+	down := []*graph.RootedNode[K, V]{}
+	for node := end; node != nil; node = node.Parent {
+		if i, ok := ancestors[node.Key]; ok {
+			slices.Reverse(down)
+			return t.toPairs(append(up[:i+1], down...)), true
+		}
+		down = append(down, node)
+	}
+
+	// Both nodes are in the tree, and so always share the root
+	return nil, false
+}
+
+// PathToRoot gets the nodes along the path from the node with the supplied key
+// up to the root, both of them included
+func (t Tree[K, V]) PathToRoot(key K) ([]Pair[K, V], bool) {
+	node, ok := t.find(key)
+	if !ok {
+		return nil, false
+	}
+	return t.toPairs(node.PathToRoot()), true
+}
+
+// IsAncestor determines whether the node with the key ancestor is upstream of
+// the node with the supplied key
+func (t Tree[K, V]) IsAncestor(ancestor, key K) bool {
+	node, ok := t.find(key)
+	if !ok {
+		return false
+	}
+
+	for node = node.Parent; node != nil; node = node.Parent {
+		if node.Key == ancestor {
+			return true
+		}
+	}
+	return false
+}
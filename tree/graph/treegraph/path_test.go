@@ -0,0 +1,67 @@
+package treegraph
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestPath(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 2})
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		tree.Upsert(key, i)
+	}
+
+	// a has b and c as its children, b has d, and c has e
+	tests := []struct {
+		from, to string
+		want     []string
+	}{
+		{"d", "c", []string{"d", "b", "a", "c"}},
+		{"c", "d", []string{"c", "a", "b", "d"}},
+		{"d", "e", []string{"d", "b", "a", "c", "e"}},
+		{"a", "e", []string{"a", "c", "e"}},
+		{"e", "a", []string{"e", "c", "a"}},
+		{"b", "d", []string{"b", "d"}},
+		{"a", "a", []string{"a"}},
+	}
+
+	for _, test := range tests {
+		path, ok := tree.Path(test.from, test.to)
+		if !ok {
+			t.Errorf("Expected a path from %s to %s", test.from, test.to)
+			continue
+		}
+
+		keys := []string{}
+		for _, pair := range path {
+			keys = append(keys, pair.Key)
+		}
+		if !slices.Equal(keys, test.want) {
+			t.Errorf("Expected the path from %s to %s to be %v, but got %v", test.from, test.to, test.want, keys)
+		}
+	}
+
+	path, _ := tree.PathToRoot("e")
+	if len(path) != 3 || path[0].Key != "e" || path[1].Key != "c" || path[2].Key != "a" {
+		t.Errorf("Expected the path from e to the root to go through c, but got %v", path)
+	}
+
+	if _, ok := tree.Path("a", "z"); ok {
+		t.Error("Expected no path to a node that is not in the tree")
+	}
+}
+
+func TestIsAncestor(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 2})
+	for i, key := range []string{"a", "b", "c", "d", "e"} {
+		tree.Upsert(key, i)
+	}
+
+	// a has b and c as its children, b has d, and c has e
+	if !tree.IsAncestor("a", "d") || !tree.IsAncestor("b", "d") {
+		t.Error("Expected a and b to be upstream of d")
+	}
+	if tree.IsAncestor("c", "d") || tree.IsAncestor("d", "b") || tree.IsAncestor("d", "d") {
+		t.Error("Expected only a and b to be upstream of d")
+	}
+}
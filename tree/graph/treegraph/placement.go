@@ -0,0 +1,270 @@
+package treegraph
+
+import (
+	"math/rand"
+	"sort"
+	"tree/graph/graph"
+	"tree/graph/maybe"
+)
+
+const (
+	// PlacementShortestSubtree places new nodes by descending from the root
+	// towards the shallowest leaf with room to spare, and replaces departing
+	// nodes with the deepest leaf of their subtree
+	PlacementShortestSubtree = "shortest-subtree"
+
+	// PlacementBreadthFirst fills the tree level by level, placing new nodes
+	// under the shallowest node with room to spare, and replaces departing nodes
+	// with the very last node of the tree, so as to keep the levels filled
+	PlacementBreadthFirst = "breadth-first"
+
+	// PlacementRandom places new nodes under a node picked at random from all
+	// nodes with room to spare, and replaces departing nodes with a leaf picked
+	// at random from their subtree. The randomness is seeded by the config
+	PlacementRandom = "random"
+)
+
+// PlacementStrategy decides where nodes go in a tree, as they join, leave, or
+// change.
+//
+// A strategy only ever makes decisions; it is the tree that carries them out,
+// and that keeps track of which nodes have been modified along the way. The
+// tree is passed to every hook, so that a strategy can inspect the tree, but a
+// strategy must not modify the tree itself
+type PlacementStrategy[K comparable, V any] interface {
+	// Join picks the node that the supplied node, along with its subtree (if
+	// any), should become a child of. The picked node must be in the tree, and
+	// have room to spare. Should no node have room to spare, nil is picked, and
+	// the node is evicted from the tree.
+	//
+	// This is called for nodes that have just joined, as well as for subtrees
+	// that need to be moved elsewhere
+	Join(tree *Tree[K, V], node *graph.RootedNode[K, V]) *graph.RootedNode[K, V]
+
+	// Leave picks the node that should take the departing node's place, which
+	// brings its own subtree (if any) along. Should nothing be picked, or should
+	// the departing node itself, one of its ancestors, or the source be picked,
+	// each of the departing node's children will be placed anew via Join
+	Leave(tree *Tree[K, V], node *graph.RootedNode[K, V]) maybe.Maybe[*graph.RootedNode[K, V]]
+
+	// Change picks the children of the node that should be moved elsewhere via
+	// Join. This is called whenever the number of children that the node is
+	// permitted to have may have changed, be it because the node's value has
+	// changed, or because the tree's config has
+	Change(tree *Tree[K, V], node *graph.RootedNode[K, V]) []*graph.RootedNode[K, V]
+}
+
+// newStrategy creates the strategy that the config calls for
+func newStrategy[K comparable, V any](config Config) PlacementStrategy[K, V] {
+	switch config.Placement {
+	case PlacementBreadthFirst:
+		return NewBreadthFirstStrategy[K, V]()
+	case PlacementRandom:
+		return NewRandomStrategy[K, V](config.Seed)
+	default:
+		return NewShortestSubtreeStrategy[K, V]()
+	}
+}
+
+// ExcessChildren gets the children that the node has beyond what it is
+// permitted to have, picking the smallest of the subtrees, since those are the
+// cheapest to move, and leaving pinned children be wherever possible. This is
+// what all strategies in this package use for Change
+func ExcessChildren[K comparable, V any](
+	tree *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) []*graph.RootedNode[K, V] {
+	limit := tree.ChildLimit(node)
+	if len(node.Children) <= limit {
+		return []*graph.RootedNode[K, V]{}
+	}
+
+	sizes := subtreeSizes(node.Children)
+
+	// Keep the pinned children and the largest of the subtrees where they are,
+	// and move the rest
+	children := append([]*graph.RootedNode[K, V]{}, node.Children...)
+	sort.SliceStable(children, func(i, j int) bool {
+		a, b := tree.IsPinned(children[i].Key), tree.IsPinned(children[j].Key)
+		if a != b {
+			return a
+		}
+		return sizes[children[i].Key] > sizes[children[j].Key]
+	})
+
+	return children[limit:]
+}
+
+type shortestSubtreeStrategy[K comparable, V any] struct{}
+
+// NewShortestSubtreeStrategy creates the strategy that trees use by default.
+// See PlacementShortestSubtree
+func NewShortestSubtreeStrategy[K comparable, V any]() PlacementStrategy[K, V] {
+	return shortestSubtreeStrategy[K, V]{}
+}
+
+// Join descends from the root towards the shallowest leaf that is able to take
+// on children, and settles on the first node along the way that has room to
+// spare. Should there be no such leaf, it settles for the shallowest node with
+// room to spare, if any
+func (shortestSubtreeStrategy[K, V]) Join(
+	tree *Tree[K, V],
+	_ *graph.RootedNode[K, V],
+) *graph.RootedNode[K, V] {
+	root, ok := tree.Root().Get()
+	if !ok {
+		return nil
+	}
+
+	order := root.BreadthFirst()
+
+	// The distance from every node to the nearest leaf in its subtree that is
+	// able to take on children. Nodes without such a leaf are absent
+	distances := map[K]int{}
+	for i := len(order) - 1; i >= 0; i-- {
+		node := order[i]
+		if len(node.Children) <= 0 {
+			if tree.HasRoom(node) {
+				distances[node.Key] = 0
+			}
+			continue
+		}
+		for _, child := range node.Children {
+			d, ok := distances[child.Key]
+			if !ok {
+				continue
+			}
+			if current, ok := distances[node.Key]; !ok || d+1 < current {
+				distances[node.Key] = d + 1
+			}
+		}
+	}
+
+	if _, ok := distances[root.Key]; !ok {
+		return breadthFirstStrategy[K, V]{}.Join(tree, nil)
+	}
+
+	node := root
+	for !tree.HasRoom(node) {
+		var next *graph.RootedNode[K, V]
+		for _, child := range node.Children {
+			d, ok := distances[child.Key]
+			if ok && (next == nil || d < distances[next.Key]) {
+				next = child
+			}
+		}
+		node = next
+	}
+	return node
+}
+
+// Leave picks the deepest leaf in the subtree of the departing node
+func (shortestSubtreeStrategy[K, V]) Leave(
+	_ *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) maybe.Maybe[*graph.RootedNode[K, V]] {
+	order := node.BreadthFirst()
+	return maybe.Something(order[len(order)-1])
+}
+
+func (shortestSubtreeStrategy[K, V]) Change(
+	tree *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) []*graph.RootedNode[K, V] {
+	return ExcessChildren(tree, node)
+}
+
+type breadthFirstStrategy[K comparable, V any] struct{}
+
+// NewBreadthFirstStrategy creates a strategy that fills the lowest level of the
+// tree first. See PlacementBreadthFirst
+func NewBreadthFirstStrategy[K comparable, V any]() PlacementStrategy[K, V] {
+	return breadthFirstStrategy[K, V]{}
+}
+
+// Join picks the shallowest node with room to spare, if any
+func (breadthFirstStrategy[K, V]) Join(
+	tree *Tree[K, V],
+	_ *graph.RootedNode[K, V],
+) *graph.RootedNode[K, V] {
+	vacancy, _ := tree.Vacancy().Get()
+	return vacancy
+}
+
+// Leave picks the very last node of the tree, in breadth-first order
+func (breadthFirstStrategy[K, V]) Leave(
+	tree *Tree[K, V],
+	_ *graph.RootedNode[K, V],
+) maybe.Maybe[*graph.RootedNode[K, V]] {
+	root, ok := tree.Root().Get()
+	if !ok {
+		return maybe.Nothing[*graph.RootedNode[K, V]]()
+	}
+
+	order := root.BreadthFirst()
+	return maybe.Something(order[len(order)-1])
+}
+
+func (breadthFirstStrategy[K, V]) Change(
+	tree *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) []*graph.RootedNode[K, V] {
+	return ExcessChildren(tree, node)
+}
+
+type randomStrategy[K comparable, V any] struct {
+	rand *rand.Rand
+}
+
+// NewRandomStrategy creates a strategy that places nodes at random, with the
+// supplied seed. See PlacementRandom
+func NewRandomStrategy[K comparable, V any](seed int64) PlacementStrategy[K, V] {
+	return randomStrategy[K, V]{rand.New(rand.NewSource(seed))}
+}
+
+// Join picks a node at random, from all nodes with room to spare, if any
+func (s randomStrategy[K, V]) Join(
+	tree *Tree[K, V],
+	_ *graph.RootedNode[K, V],
+) *graph.RootedNode[K, V] {
+	root, ok := tree.Root().Get()
+	if !ok {
+		return nil
+	}
+
+	order := root.BreadthFirst()
+
+	candidates := []*graph.RootedNode[K, V]{}
+	for _, node := range order {
+		if tree.HasRoom(node) {
+			candidates = append(candidates, node)
+		}
+	}
+
+	if len(candidates) <= 0 {
+		return nil
+	}
+
+	return candidates[s.rand.Intn(len(candidates))]
+}
+
+// Leave picks a leaf at random, from the subtree of the departing node
+func (s randomStrategy[K, V]) Leave(
+	_ *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) maybe.Maybe[*graph.RootedNode[K, V]] {
+	leaves := []*graph.RootedNode[K, V]{}
+	for _, n := range node.BreadthFirst() {
+		if len(n.Children) <= 0 {
+			leaves = append(leaves, n)
+		}
+	}
+	return maybe.Something(leaves[s.rand.Intn(len(leaves))])
+}
+
+func (randomStrategy[K, V]) Change(
+	tree *Tree[K, V],
+	node *graph.RootedNode[K, V],
+) []*graph.RootedNode[K, V] {
+	return ExcessChildren(tree, node)
+}
@@ -0,0 +1,99 @@
+package treegraph
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func TestPlacementStrategiesKeepTree(t *testing.T) {
+	for _, placement := range []string{
+		PlacementShortestSubtree,
+		PlacementBreadthFirst,
+		PlacementRandom,
+	} {
+		t.Run(placement, func(t *testing.T) {
+			config := Config{MaxDegree: 3, RootDegree: 4, Placement: placement, Seed: 42}
+			tree := New[string, int](config)
+			r := rand.New(rand.NewSource(1))
+
+			present := map[string]bool{}
+			for i := 0; i < 300; i++ {
+				key := fmt.Sprint(r.Intn(60))
+				if present[key] {
+					tree.DeleteByKey(key)
+					delete(present, key)
+				} else {
+					tree.Upsert(key, i)
+					present[key] = true
+				}
+			}
+
+			list := tree.AdjacencyList()
+			if len(list) != len(present) {
+				t.Errorf("Expected the tree to have %d nodes, but got %d", len(present), len(list))
+			}
+			for key := range present {
+				if _, ok := list[key]; !ok {
+					t.Errorf("Expected node %s to be in the tree", key)
+				}
+			}
+
+			root, ok := tree.Root().Get()
+			if !ok {
+				t.Fatal("Expected the tree to not be empty")
+			}
+			checkDegrees(t, list, root.Key, config)
+
+			if !IsTree(list) {
+				t.Error("Expected the graph to be a tree, but ended up not being a tree")
+			}
+		})
+	}
+}
+
+func TestBreadthFirstFillsLevels(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 3, Placement: PlacementBreadthFirst})
+
+	// 1 root, 3 nodes at depth 1, and 6 nodes at depth 2
+	for i := 0; i < 10; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	for i := 0; i < 10; i++ {
+		position, _ := tree.GetPositionOfNode(fmt.Sprint(i))
+		if position.Depth > 2 {
+			t.Errorf("Expected node %d to be at most at a depth of 2, but it is at %d", i, position.Depth)
+		}
+	}
+
+	// Removing a node from the middle of the tree should be filled in by a node
+	// from the lowest level
+	tree.DeleteByKey("1")
+	tree.Upsert("10", 10)
+
+	for i := 2; i <= 10; i++ {
+		position, _ := tree.GetPositionOfNode(fmt.Sprint(i))
+		if position.Depth > 2 {
+			t.Errorf("Expected node %d to be at most at a depth of 2, but it is at %d", i, position.Depth)
+		}
+	}
+}
+
+func TestRandomPlacementIsSeeded(t *testing.T) {
+	build := func(seed int64) Tree[string, int] {
+		tree := New[string, int](Config{MaxDegree: 4, RootDegree: 4, Placement: PlacementRandom, Seed: seed})
+		for i := 0; i < 50; i++ {
+			tree.Upsert(fmt.Sprint(i), i)
+		}
+		for i := 0; i < 50; i += 7 {
+			tree.DeleteByKey(fmt.Sprint(i))
+		}
+		return tree
+	}
+
+	a, b := build(7), build(7)
+	if !a.AdjacencyList().Equal(b.AdjacencyList()) {
+		t.Error("Expected two trees with the same seed to end up with the same shape")
+	}
+}
@@ -0,0 +1,175 @@
+package treegraph
+
+import (
+	"tree/graph/graph"
+	"tree/graph/set"
+)
+
+// Shape summarizes how lopsided a tree is
+type Shape struct {
+	// Nodes is the number of nodes in the tree
+	Nodes int
+
+	// Height is the depth of the deepest node
+	Height int
+
+	// MeanDepth is the average depth of the nodes
+	MeanDepth float64
+
+	// DepthVariance is the variance of the depth of the nodes
+	DepthVariance float64
+}
+
+// Shape gets a summary of how lopsided the tree is
+func (t Tree[K, V]) Shape() Shape {
+	root, ok := t.maybeRoot.Get()
+	if !ok {
+		return Shape{}
+	}
+
+	depths := []int{}
+	for _, leveled := range levels(root) {
+		depths = append(depths, leveled.depth)
+	}
+
+	shape := Shape{Nodes: len(depths)}
+	sum := 0
+	for _, depth := range depths {
+		sum += depth
+		if depth > shape.Height {
+			shape.Height = depth
+		}
+	}
+	shape.MeanDepth = float64(sum) / float64(len(depths))
+
+	for _, depth := range depths {
+		d := float64(depth) - shape.MeanDepth
+		shape.DepthVariance += d * d
+	}
+	shape.DepthVariance /= float64(len(depths))
+
+	return shape
+}
+
+// MinimumHeight gets the height of the shallowest tree that the config permits,
+// for a tree with as many nodes as this one
+func (t Tree[K, V]) MinimumHeight() int {
+	config := t.Config()
+	nodes := len(t.nodes)
+
+	height := 0
+	capacity := 1
+	width := 1
+	for capacity < nodes {
+		if height == 0 {
+			width = config.RootDegree
+		} else {
+			width *= config.MaxDegree - 1
+		}
+		capacity += width
+		height++
+	}
+	return height
+}
+
+// NeedsRebalancing determines whether the tree has grown more lopsided than
+// its config permits
+func (t Tree[K, V]) NeedsRebalancing() bool {
+	config := t.Config().Rebalance
+	shape := t.Shape()
+
+	if shape.Height > t.MinimumHeight()+config.HeightSlack {
+		return true
+	}
+
+	return config.DepthVariance > 0 && shape.DepthVariance > config.DepthVariance
+}
+
+// Rebalance moves the deepest leaves of the tree to the shallowest nodes with
+// room to spare, for as long as the tree needs rebalancing, up to the number of
+// moves that the config permits. Only leaves that `canMove` holds for are moved;
+// neither the source, suspended nodes, nor pinned nodes are ever moved.
+//
+// Returns the keys of the nodes that were moved, along with the keys of all
+// nodes whose neighbors have changed
+func (t *Tree[K, V]) Rebalance(canMove func(key K) bool) ([]K, set.Set[K]) {
+	moved := []K{}
+	modified := set.Set[K]{}
+
+	for len(moved) < t.Config().Rebalance.Moves && t.NeedsRebalancing() {
+		leaf, vacancy, ok := t.nextMove(canMove)
+		if !ok {
+			break
+		}
+
+		moved = append(moved, leaf.Key)
+		modified = modified.Union(leaf.Detach())
+		modified = modified.Union(t.attach(vacancy, leaf))
+	}
+
+	return moved, modified
+}
+
+// nextMove finds the deepest leaf that is able to move at least two levels up,
+// along with the shallowest node with room to spare that it should move to
+func (t Tree[K, V]) nextMove(
+	canMove func(key K) bool,
+) (*graph.RootedNode[K, V], *graph.RootedNode[K, V], bool) {
+	root, ok := t.maybeRoot.Get()
+	if !ok {
+		return nil, nil, false
+	}
+
+	order := levels(root)
+
+	var vacancy leveledNode[K, V]
+	found := false
+	for _, leveled := range order {
+		if t.HasRoom(leveled.node) {
+			vacancy = leveled
+			found = true
+			break
+		}
+	}
+	if !found {
+		return nil, nil, false
+	}
+
+	for i := len(order) - 1; i >= 0; i-- {
+		leveled := order[i]
+		if leveled.depth <= vacancy.depth+1 {
+			break
+		}
+
+		node := leveled.node
+		if len(node.Children) > 0 ||
+			node.IsRoot() ||
+			t.isSource(node.Key) ||
+			t.IsSuspended(node.Key) ||
+			t.IsPinned(node.Key) ||
+			!canMove(node.Key) {
+			continue
+		}
+
+		return node, vacancy.node, true
+	}
+
+	return nil, nil, false
+}
+
+type leveledNode[K comparable, V any] struct {
+	node  *graph.RootedNode[K, V]
+	depth int
+}
+
+// levels gets all nodes in the subtree of the node, in breadth-first order,
+// along with their depth relative to the node
+func levels[K comparable, V any](node *graph.RootedNode[K, V]) []leveledNode[K, V] {
+	order := []leveledNode[K, V]{{node, 0}}
+	for i := 0; i < len(order); i++ {
+		for _, child := range order[i].node.Children {
+			order = append(order, leveledNode[K, V]{child, order[i].depth + 1})
+		}
+	}
+	return order
+}
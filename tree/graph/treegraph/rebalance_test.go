@@ -0,0 +1,78 @@
+package treegraph
+
+import (
+	"fmt"
+	"testing"
+)
+
+// chain builds a tree in which every node has a single child, by having every
+// node declare room for just the one child, and then lifting the limit
+func chain(config Config, n int) Tree[string, capacityValue] {
+	tree := New[string, capacityValue](config)
+	for i := 0; i < n; i++ {
+		tree.Upsert(fmt.Sprint(i), capacityValue{1})
+	}
+	for i := 0; i < n; i++ {
+		tree.Upsert(fmt.Sprint(i), capacityValue{-1})
+	}
+	return tree
+}
+
+func TestShape(t *testing.T) {
+	tree := chain(Config{MaxDegree: 3, RootDegree: 2}, 3)
+
+	shape := tree.Shape()
+	if shape.Nodes != 3 || shape.Height != 2 || shape.MeanDepth != 1 {
+		t.Errorf("Expected 3 nodes, a height of 2 and a mean depth of 1, but got %+v", shape)
+	}
+	if variance := 2.0 / 3.0; shape.DepthVariance != variance {
+		t.Errorf("Expected a depth variance of %f, but got %f", variance, shape.DepthVariance)
+	}
+
+	if height := tree.MinimumHeight(); height != 1 {
+		t.Errorf("Expected a minimum height of 1, but got %d", height)
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	config := Config{MaxDegree: 3, RootDegree: 2}
+	config.Rebalance.Moves = 1000
+	tree := chain(config, 31)
+
+	if !tree.NeedsRebalancing() {
+		t.Fatal("Expected a chain to need rebalancing")
+	}
+
+	moved, modified := tree.Rebalance(func(string) bool { return true })
+
+	if violations := tree.Validate(); len(violations) > 0 {
+		t.Fatalf("Expected the tree to still be valid, but got %v", violations)
+	}
+
+	if tree.NeedsRebalancing() {
+		t.Errorf("Expected the tree to have been rebalanced, but got %+v", tree.Shape())
+	}
+
+	for _, key := range moved {
+		if !modified.Has(key) {
+			t.Errorf("Expected the moved %s to have been modified", key)
+		}
+	}
+}
+
+func TestRebalanceLimits(t *testing.T) {
+	config := Config{MaxDegree: 3, RootDegree: 2}
+	config.Rebalance.Moves = 2
+	tree := chain(config, 10)
+
+	// The deepest node is the only leaf of a chain
+	moved, _ := tree.Rebalance(func(key string) bool { return key != "9" })
+	if len(moved) != 0 {
+		t.Errorf("Expected 9 to have been left alone, but got %v", moved)
+	}
+
+	moved, _ = tree.Rebalance(func(string) bool { return true })
+	if len(moved) != 2 {
+		t.Errorf("Expected 2 nodes to have been moved, but got %v", moved)
+	}
+}
@@ -0,0 +1,43 @@
+package treegraph
+
+import "tree/graph/set"
+
+// Suspend marks the node with the supplied key as temporarily unreachable.
+//
+// A suspended node keeps its place in the tree, but is not given any new
+// children for as long as it is suspended. The returned set holds the keys of
+// the node and its neighbors, all of whom ought to be told about the node
+func (t *Tree[K, V]) Suspend(key K) set.Set[K] {
+	node, ok := t.find(key)
+	if !ok {
+		return set.Set[K]{}
+	}
+
+	if t.suspended == nil {
+		t.suspended = set.Set[K]{}
+	}
+	t.suspended.Add(key)
+
+	return node.GetNeighborKeys().Union(set.New(key))
+}
+
+// Resume marks the node with the supplied key as reachable again. The returned
+// set holds the keys of the node and its neighbors
+func (t *Tree[K, V]) Resume(key K) set.Set[K] {
+	if !t.IsSuspended(key) {
+		return set.Set[K]{}
+	}
+	delete(t.suspended, key)
+
+	node, ok := t.find(key)
+	if !ok {
+		return set.Set[K]{}
+	}
+
+	return node.GetNeighborKeys().Union(set.New(key))
+}
+
+// IsSuspended determines whether the node with the supplied key is suspended
+func (t Tree[K, V]) IsSuspended(key K) bool {
+	return t.suspended.Has(key)
+}
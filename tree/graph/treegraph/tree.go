@@ -7,39 +7,307 @@ import (
 	"tree/graph/set"
 )
 
+// Tree is a rooted tree, whose shape is governed by its config.
+//
+// A tree may optionally have a source: a node that is pinned as the root of the
+// tree, and is never relocated for as long as it is part of the tree. This is
+// what a broadcast would typically be structured as, with the publisher at the
+// root, and everything flowing down towards the leaves
+//
+// Where nodes go, as they join, leave, or change, is decided by the tree's
+// placement strategy, which is picked by the config. Nodes are only ever placed
+// under nodes with room to spare; a node, or a subtree, that there is no room
+// for anywhere in the tree is evicted from the tree instead. See TakeEvicted
+//
+// Every node is also indexed by its key, so that looking up a node does not
+// involve walking the tree
 type Tree[K comparable, V any] struct {
-	maybeRoot maybe.Maybe[*Node[K, V]]
+	maybeRoot   maybe.Maybe[*graph.RootedNode[K, V]]
+	nodes       map[K]*graph.RootedNode[K, V]
+	config      Config
+	maybeSource maybe.Maybe[K]
+	strategy    PlacementStrategy[K, V]
+	stats       DeletionStats
+	suspended   set.Set[K]
+	pinned      set.Set[K]
+	evicted     []Pair[K, V]
 }
 
+// New creates a new empty tree, whose shape is governed by the supplied config
+func New[K comparable, V any](config Config) Tree[K, V] {
+	config = config.normalized()
+	return Tree[K, V]{
+		maybe.Nothing[*graph.RootedNode[K, V]](),
+		map[K]*graph.RootedNode[K, V]{},
+		config,
+		maybe.Nothing[K](),
+		newStrategy[K, V](config),
+		DeletionStats{},
+		set.Set[K]{},
+		set.Set[K]{},
+		nil,
+	}
+}
+
+// Config gets the configuration that the tree is currently being laid out by.
+//
+// A tree that was not created via `New` will be using the default config
+func (t Tree[K, V]) Config() Config {
+	if t.config == (Config{}) {
+		return DefaultConfig()
+	}
+	return t.config
+}
+
+// SetConfig replaces the configuration of the tree.
+//
+// Rather than rebuilding the tree from scratch, only the nodes that end up with
+// more neighbors than the new configuration permits are touched: their excess
+// subtrees get moved to wherever there is room. The returned set contains the
+// keys of all nodes whose neighbors have changed
+func (t *Tree[K, V]) SetConfig(config Config) set.Set[K] {
+	config = config.normalized()
+
+	// Only swap out the strategy when asked for a different one, so as not to,
+	// say, reset the state of a random strategy
+	old := t.Config()
+	if t.strategy == nil ||
+		old.Placement != config.Placement ||
+		old.Seed != config.Seed {
+		t.strategy = newStrategy[K, V](config)
+	}
+
+	t.config = config
+	return t.relayout(func(K) bool { return true })
+}
+
+// SetStrategy replaces the placement strategy of the tree with one that is not
+// otherwise available via the config. The tree is left as is; only later
+// changes to the tree will be subject to the new strategy
+func (t *Tree[K, V]) SetStrategy(strategy PlacementStrategy[K, V]) {
+	t.strategy = strategy
+}
+
+// placementStrategy gets the tree's placement strategy, creating one based on
+// the config if the tree was not created via `New`
+func (t *Tree[K, V]) placementStrategy() PlacementStrategy[K, V] {
+	if t.strategy == nil {
+		t.strategy = newStrategy[K, V](t.Config())
+	}
+	return t.strategy
+}
+
+// Root gets the root of the tree, if the tree is not empty
+func (t Tree[K, V]) Root() maybe.Maybe[*graph.RootedNode[K, V]] {
+	return t.maybeRoot
+}
+
+// Source gets the key of the node that is pinned as the root of the tree, if
+// any
+func (t Tree[K, V]) Source() maybe.Maybe[K] {
+	return t.maybeSource
+}
+
+// IsRooted determines whether the tree has a source pinned as its root
+func (t Tree[K, V]) IsRooted() bool {
+	_, ok := t.maybeSource.Get()
+	return ok
+}
+
+// SetSource pins the node with the supplied key as the root of the tree.
+//
+// If the node is already in the tree, the tree gets rerooted at the node, which
+// only flips the orientation of the edges between the node and the old root.
+// Otherwise, the node will become the root as soon as it gets upserted.
+//
+// Since the depth of every node may change, in a rooted tree, the returned set
+// holds the keys of every node whose parent, children or depth has changed
+func (t *Tree[K, V]) SetSource(key K) set.Set[K] {
+	t.maybeSource = maybe.Something(key)
+
+	root, ok := t.maybeRoot.Get()
+	if !ok || root.Key == key {
+		return set.Set[K]{}
+	}
+
+	node, ok := t.find(key)
+	if !ok {
+		return set.Set[K]{}
+	}
+
+	node.Reroot()
+	t.maybeRoot = maybe.Something(node)
+
+	// Every node's depth is liable to have changed, and the old root is now
+	// subject to the limits of every other node
+	return t.subtreeKeys(node).Union(t.relayout(func(k K) bool {
+		return k == key || k == root.Key
+	}))
+}
+
+// UnsetSource unpins the source from the root of the tree. The tree is left
+// as is; only later changes to the tree will be free to move the old source
+func (t *Tree[K, V]) UnsetSource() {
+	t.maybeSource = maybe.Nothing[K]()
+}
+
+// isSource determines whether the supplied key is that of the source
+func (t Tree[K, V]) isSource(key K) bool {
+	source, ok := t.maybeSource.Get()
+	return ok && source == key
+}
+
+// Upsert takes the key and value, and upserts it into the tree. That is, if a
+// node with the key exists, the value of the node gets replaced by the supplied
+// value; otherwise, a new node will be created as a leaf of whichever node the
+// placement picks, unless the node is the source, in which case it becomes the
+// new root. A new node that no node has room for takes over as the root should
+// it have room for the root itself (see canRise), and is evicted right away
+// otherwise.
+//
+// Since a new value may very well declare a smaller capacity than the old one,
+// any children that the node is no longer able to hold get moved elsewhere.
+// The neighbors of a node whose value got replaced are among the modified
+// nodes, since they ought to be told about the new value
 func (t *Tree[K, V]) Upsert(key K, value V) set.Set[K] {
-	n, ok := t.maybeRoot.Get()
+	if node, ok := t.find(key); ok {
+		node.Value = value
+		modified := node.GetNeighborKeys().Union(set.New(key))
+		return modified.Union(t.relayout(func(k K) bool { return k == key }))
+	}
+
+	node := graph.NewRootedNode(key, value)
+	t.index(node)
+
+	root, ok := t.maybeRoot.Get()
 	if !ok {
-		t.maybeRoot = maybe.Something(&Node[K, V]{[]*graph.Node[K, V]{}, key, value})
+		t.maybeRoot = maybe.Something(node)
 		return set.New(key)
 	}
 
-	s := n.Upsert(key, value, 3, set.Set[K]{})
-	t.maybeRoot = maybe.Something(n)
-	return s
+	if t.isSource(key) {
+		// The source always sits at the root, and thus the entire tree gets moved
+		// underneath it
+		modified := t.attach(node, root)
+		t.maybeRoot = maybe.Something(node)
+		return modified.Union(t.relayout(func(k K) bool {
+			return k == key || k == root.Key
+		}))
+	}
+
+	if _, ok := t.Vacancy().Get(); !ok && t.canRise(node) {
+		modified := t.attach(node, root)
+		t.maybeRoot = maybe.Something(node)
+		return modified
+	}
+
+	return t.place(node)
 }
 
-func (t *Tree[K, V]) DeleteByKey(key interface{}) set.Set[K] {
-	n, ok := t.maybeRoot.Get()
+// DeleteByKey removes the node with the supplied key from the tree, repairing
+// the tree according to the deletion mode in the config. See the Deletion*
+// constants.
+//
+// Deleting the source unpins it, so that another node may become the source.
+// Suspending the source, on the other hand, leaves it pinned
+func (t *Tree[K, V]) DeleteByKey(key K) set.Set[K] {
+	node, ok := t.find(key)
 	if !ok {
 		return set.Set[K]{}
 	}
 
-	maybeRoot, modifiedNodes := n.DeleteByKey(key, set.Set[K]{})
-	t.maybeRoot = maybeRoot
-	return modifiedNodes
+	delete(t.nodes, key)
+	delete(t.suspended, key)
+	delete(t.pinned, key)
+	if t.isSource(key) {
+		t.UnsetSource()
+	}
+
+	var modified set.Set[K]
+	switch t.Config().Deletion {
+	case DeletionPromote:
+		modified = t.promote(node)
+	case DeletionReattach:
+		modified = t.reattach(node)
+	default:
+		modified = t.replace(node)
+	}
+
+	t.recordDeletion(key, modified)
+	return modified
 }
 
-func (t Tree[K, V]) Find(key K) (maybe.Maybe[V], bool) {
-	n, ok := t.maybeRoot.Get()
-	if !ok {
-		return maybe.Nothing[V](), false
+// replace removes the node from the tree, and has the placement strategy pick
+// the node that takes its place. Should the strategy not pick one, each of the
+// node's children are placed anew, with the first of them taking over as the
+// root, if the node is the root
+func (t *Tree[K, V]) replace(node *graph.RootedNode[K, V]) set.Set[K] {
+	root, _ := t.maybeRoot.Get()
+
+	replacement, ok := t.placementStrategy().Leave(t, node).Get()
+	if ok && t.canReplace(node, replacement) {
+		// The replacement's own subtree, if any, is moving along with it
+		modified := replacement.Detach().Union(t.subtreeKeys(replacement))
+		modified = modified.Union(node.ReplaceWith(replacement))
+		if node == root {
+			t.maybeRoot = maybe.Something(replacement)
+		}
+
+		// Having inherited the deleted node's children, the replacement may very
+		// well have more children than it is permitted to have
+		return modified.Union(t.relayout(func(k K) bool {
+			return k == replacement.Key
+		}))
+	}
+
+	orphans := append([]*graph.RootedNode[K, V]{}, node.Children...)
+	modified := node.Detach()
+	for _, orphan := range orphans {
+		modified = modified.Union(orphan.Detach())
+	}
+
+	if node == root {
+		if len(orphans) <= 0 {
+			t.maybeRoot = maybe.Nothing[*graph.RootedNode[K, V]]()
+			return modified
+		}
+
+		t.maybeRoot = maybe.Something(orphans[0])
+		modified = modified.Union(t.subtreeKeys(orphans[0]))
+
+		// The root may very well be permitted fewer children than the new root
+		// already has
+		newRoot := orphans[0]
+		modified = modified.Union(t.relayout(func(k K) bool { return k == newRoot.Key }))
+		orphans = orphans[1:]
 	}
-	node, ok := (*graph.Node[K, V])(n).Find(key)
+
+	for _, orphan := range orphans {
+		modified = modified.Union(t.place(orphan))
+	}
+
+	return modified
+}
+
+// canReplace determines whether the replacement is able to take the node's
+// place, without breaking the tree, or moving the source or a pinned node
+func (t Tree[K, V]) canReplace(node, replacement *graph.RootedNode[K, V]) bool {
+	if replacement == nil || t.isSource(replacement.Key) || t.IsPinned(replacement.Key) {
+		return false
+	}
+
+	// This also catches the node being its own replacement
+	for _, ancestor := range node.PathToRoot() {
+		if ancestor == replacement {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (t Tree[K, V]) Find(key K) (maybe.Maybe[V], bool) {
+	node, ok := t.find(key)
 	if !ok {
 		return maybe.Nothing[V](), false
 	}
@@ -47,49 +315,279 @@ func (t Tree[K, V]) Find(key K) (maybe.Maybe[V], bool) {
 	return maybe.Something(node.Value), true
 }
 
+// find gets the node with the supplied key, via the index
+func (t Tree[K, V]) find(key K) (*graph.RootedNode[K, V], bool) {
+	node, ok := t.nodes[key]
+	return node, ok
+}
+
+// index adds the node to the index, creating the index if the tree was not
+// created via `New`
+func (t *Tree[K, V]) index(node *graph.RootedNode[K, V]) {
+	if t.nodes == nil {
+		t.nodes = map[K]*graph.RootedNode[K, V]{}
+	}
+	t.nodes[node.Key] = node
+}
+
 type Pair[K comparable, V any] struct {
 	Key   K
 	Value V
+
+	// Suspended is set for nodes that are temporarily unreachable
+	Suspended bool `json:",omitempty"`
 }
 
 func (t Tree[K, V]) GetNeighborsOfNode(key K) ([]Pair[K, V], bool) {
-	// Get root node
-	n, ok := t.maybeRoot.Get()
+	node, ok := t.find(key)
 	if !ok {
 		return nil, false
 	}
 
-	// Gets all nodes with the given key
-	node, ok := (*graph.Node[K, V])(n).Find(key)
+	return t.toPairs(node.Neighbors()), true
+}
+
+// Position describes where a node sits in the tree, relative to the root
+type Position[K comparable, V any] struct {
+	Parent   maybe.Maybe[Pair[K, V]]
+	Children []Pair[K, V]
+	Depth    int
+}
+
+// GetPositionOfNode gets the parent, the children and the depth of the node
+// with the supplied key
+func (t Tree[K, V]) GetPositionOfNode(key K) (Position[K, V], bool) {
+	node, ok := t.find(key)
 	if !ok {
-		return nil, false
+		return Position[K, V]{}, false
 	}
 
-	pairs := []Pair[K, V]{}
-	for _, neighbor := range node.Neighbors {
-		pairs = append(pairs, Pair[K, V]{neighbor.Key, neighbor.Value})
+	parent := maybe.Nothing[Pair[K, V]]()
+	if node.Parent != nil {
+		parent = maybe.Something(t.toPair(node.Parent))
 	}
 
-	return pairs, true
+	return Position[K, V]{
+		Parent:   parent,
+		Children: t.toPairs(node.Children),
+		Depth:    node.Depth(),
+	}, true
 }
 
 func (t Tree[K, V]) Has(key K) bool {
-	n, ok := t.maybeRoot.Get()
-	if !ok {
-		return false
-	}
-	return (*graph.Node[K, V])(n).Has(key)
+	_, ok := t.find(key)
+	return ok
 }
 
 func (t Tree[K, V]) AdjacencyList() adjacencylist.AdjacencyList[K, V] {
-	n, ok := t.maybeRoot.Get()
+	root, ok := t.maybeRoot.Get()
 	if !ok {
 		return adjacencylist.AdjacencyList[K, V]{}
 	}
-	return (*graph.Node[K, V])(n).AdjacencyList(set.Set[K]{})
+	return root.AdjacencyList()
+}
+
+// Len gets the number of nodes in the tree, suspended nodes included
+func (t Tree[K, V]) Len() int {
+	return len(t.nodes)
 }
 
 func (t Tree[K, V]) IsEmpty() bool {
 	_, ok := t.maybeRoot.Get()
 	return !ok
 }
+
+// configChildLimit gets the maximum number of children that the tree's config
+// permits a node to have
+func (t Tree[K, V]) configChildLimit(node *graph.RootedNode[K, V]) int {
+	config := t.Config()
+	if node.IsRoot() {
+		return config.RootDegree
+	}
+	return config.MaxDegree - 1
+}
+
+// ChildLimit gets the maximum number of children that the node may have,
+// taking into account the capacity that the node has declared for itself
+func (t Tree[K, V]) ChildLimit(node *graph.RootedNode[K, V]) int {
+	return t.declaredChildLimit(node, t.configChildLimit(node))
+}
+
+// declaredChildLimit caps the supplied limit at the capacity that the node has
+// declared for itself
+func (t Tree[K, V]) declaredChildLimit(node *graph.RootedNode[K, V], limit int) int {
+	declarer, ok := any(node.Value).(CapacityDeclarer)
+	if !ok {
+		return limit
+	}
+
+	declared, ok := declarer.DeclaredCapacity().children(t.Config()).Get()
+	if ok && declared < limit {
+		return declared
+	}
+
+	return limit
+}
+
+// HasRoom determines whether the node is able to take on another child. A
+// suspended node never has room
+func (t Tree[K, V]) HasRoom(node *graph.RootedNode[K, V]) bool {
+	return len(node.Children) < t.ChildLimit(node) && !t.IsSuspended(node.Key)
+}
+
+// Vacancy finds the shallowest node in the tree that has room for one more
+// child. See HasRoom
+func (t Tree[K, V]) Vacancy() maybe.Maybe[*graph.RootedNode[K, V]] {
+	root, ok := t.maybeRoot.Get()
+	if !ok {
+		return maybe.Nothing[*graph.RootedNode[K, V]]()
+	}
+
+	for _, node := range root.BreadthFirst() {
+		if t.HasRoom(node) {
+			return maybe.Something(node)
+		}
+	}
+	return maybe.Nothing[*graph.RootedNode[K, V]]()
+}
+
+// HasVacancy determines whether a new node with the supplied key and value
+// would be placed in the tree, rather than evicted right away. An empty tree
+// always has room for its root
+func (t Tree[K, V]) HasVacancy(key K, value V) bool {
+	_, ok := t.Vacancy().Get()
+	return ok || t.IsEmpty() || t.canRise(graph.NewRootedNode(key, value))
+}
+
+// canRise determines whether the new node, for want of a node with room for it,
+// is able to take over as the root instead, with the old root as its only
+// child. This keeps a root that declared no room for children, such as the
+// first of a tree's viewers, from keeping everyone else out. The source and
+// pinned nodes are never moved underneath the new node, and neither is a root
+// with more children than any other node may have
+func (t Tree[K, V]) canRise(node *graph.RootedNode[K, V]) bool {
+	root, ok := t.maybeRoot.Get()
+	if !ok || t.isSource(root.Key) || t.IsPinned(root.Key) || !t.HasRoom(node) {
+		return false
+	}
+	return len(root.Children) <= t.declaredChildLimit(root, t.Config().MaxDegree-1)
+}
+
+// join asks the placement strategy for the node that the supplied node should
+// be attached to, falling back to the shallowest node with room to spare should
+// the strategy pick something that is not in the tree, or that has no room.
+// Returns false should no node have room to spare
+func (t *Tree[K, V]) join(node *graph.RootedNode[K, V]) (*graph.RootedNode[K, V], bool) {
+	root, _ := t.maybeRoot.Get()
+
+	parent := t.placementStrategy().Join(t, node)
+	if parent == nil || parent.Root() != root || !t.HasRoom(parent) {
+		return t.Vacancy().Get()
+	}
+
+	return parent, true
+}
+
+// place attaches the detached subtree wherever the placement strategy picks,
+// evicting the subtree should no node have room to spare. Returns the set of
+// all nodes that were modified
+func (t *Tree[K, V]) place(subtree *graph.RootedNode[K, V]) set.Set[K] {
+	parent, ok := t.join(subtree)
+	if !ok {
+		t.evict(subtree)
+		return set.Set[K]{}
+	}
+	return t.attach(parent, subtree)
+}
+
+// evict removes every node in the detached subtree from the tree, to be picked
+// up via TakeEvicted
+func (t *Tree[K, V]) evict(subtree *graph.RootedNode[K, V]) {
+	for _, node := range subtree.BreadthFirst() {
+		t.evicted = append(t.evicted, t.toPair(node))
+		delete(t.nodes, node.Key)
+		delete(t.suspended, node.Key)
+		delete(t.pinned, node.Key)
+	}
+}
+
+// TakeEvicted gets the nodes that have been evicted from the tree, for want of
+// a node with room for them, since the last call, in the order in which they
+// were evicted. A parent is always evicted before its children
+func (t *Tree[K, V]) TakeEvicted() []Pair[K, V] {
+	evicted := t.evicted
+	t.evicted = nil
+	return evicted
+}
+
+// attach adds the subtree as a child of the parent, and returns the set of all
+// nodes that were modified
+func (t Tree[K, V]) attach(parent, subtree *graph.RootedNode[K, V]) set.Set[K] {
+	return parent.AddChild(subtree).Union(t.subtreeKeys(subtree))
+}
+
+// relayout moves subtrees away from any of the included nodes, as picked by the
+// placement strategy, and returns the set of all nodes that were modified
+func (t *Tree[K, V]) relayout(include func(key K) bool) set.Set[K] {
+	modified := set.Set[K]{}
+
+	root, ok := t.maybeRoot.Get()
+	if !ok {
+		return modified
+	}
+
+	// Settle on everything that needs moving before moving anything, since a
+	// detached subtree would otherwise be mistaken for a root
+	excess := []*graph.RootedNode[K, V]{}
+	for _, node := range root.BreadthFirst() {
+		if !include(node.Key) {
+			continue
+		}
+		for _, child := range t.placementStrategy().Change(t, node) {
+			if child.Parent == node {
+				excess = append(excess, child)
+			}
+		}
+	}
+
+	for _, subtree := range excess {
+		modified = modified.Union(subtree.Detach())
+	}
+
+	for _, subtree := range excess {
+		modified = modified.Union(t.place(subtree))
+	}
+
+	return modified
+}
+
+// subtreeKeys gets the keys of the nodes that are affected by the subtree of
+// the supplied node having been moved. In a rooted tree, every node in the
+// subtree has had its depth changed; otherwise it is only the node itself
+func (t Tree[K, V]) subtreeKeys(node *graph.RootedNode[K, V]) set.Set[K] {
+	if !t.IsRooted() {
+		return set.New(node.Key)
+	}
+
+	keys := set.Set[K]{}
+	for _, n := range node.BreadthFirst() {
+		keys.Add(n.Key)
+	}
+	return keys
+}
+
+func (t Tree[K, V]) toPair(node *graph.RootedNode[K, V]) Pair[K, V] {
+	return Pair[K, V]{
+		Key:       node.Key,
+		Value:     node.Value,
+		Suspended: t.IsSuspended(node.Key),
+	}
+}
+
+func (t Tree[K, V]) toPairs(nodes []*graph.RootedNode[K, V]) []Pair[K, V] {
+	pairs := []Pair[K, V]{}
+	for _, node := range nodes {
+		pairs = append(pairs, t.toPair(node))
+	}
+	return pairs
+}
@@ -0,0 +1,453 @@
+package treegraph
+
+import (
+	"fmt"
+	"testing"
+	"tree/graph/adjacencylist"
+	"tree/graph/maybe"
+	"tree/graph/set"
+)
+
+func checkDegrees[K comparable, V any](
+	t *testing.T,
+	list adjacencylist.AdjacencyList[K, V],
+	root K,
+	config Config,
+) {
+	for key, node := range list {
+		limit := config.MaxDegree
+		if key == root {
+			limit = config.RootDegree
+		}
+		if len(node.Neighbors) > limit {
+			t.Errorf("Expected node %v to have at most %d neighbors, but it has %d", key, limit, len(node.Neighbors))
+		}
+	}
+}
+
+func TestTreeRootDegree(t *testing.T) {
+	config := Config{MaxDegree: 3, RootDegree: 5}
+	tree := New[string, int](config)
+
+	for i := 0; i < 6; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	list := tree.AdjacencyList()
+	if len(list["0"].Neighbors) != 5 {
+		t.Errorf("Expected the root to have 5 neighbors, but got %d", len(list["0"].Neighbors))
+	}
+
+	checkDegrees(t, list, "0", config)
+}
+
+func TestTreeMaxDegree(t *testing.T) {
+	config := Config{MaxDegree: 2, RootDegree: 1}
+	tree := New[string, int](config)
+
+	for i := 0; i < 20; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	list := tree.AdjacencyList()
+	if len(list) != 20 {
+		t.Errorf("Expected the tree to have 20 nodes, but got %d", len(list))
+	}
+
+	checkDegrees(t, list, "0", config)
+
+	if !IsTree(list) {
+		t.Error("Expected the graph to be a tree, but ended up not being a tree")
+	}
+}
+
+func TestTreeSetConfig(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 7, RootDegree: 6})
+
+	for i := 0; i < 100; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	before := tree.AdjacencyList()
+
+	config := Config{MaxDegree: 3, RootDegree: 2}
+	modified := tree.SetConfig(config)
+
+	list := tree.AdjacencyList()
+	if len(list) != 100 {
+		t.Errorf("Expected the tree to still have 100 nodes, but got %d", len(list))
+	}
+
+	checkDegrees(t, list, "0", config)
+
+	if !IsTree(list) {
+		t.Error("Expected the graph to be a tree, but ended up not being a tree")
+	}
+
+	for key, node := range list {
+		if !node.Neighbors.Equals(before[key].Neighbors) && !modified.Has(key) {
+			t.Errorf("Node %s had its neighbors changed, but was not reported as modified", key)
+		}
+	}
+
+	if len(modified) >= len(list) {
+		t.Errorf("Expected only some of the nodes to be modified, but all %d were", len(modified))
+	}
+}
+
+func TestTreeSetConfigGrow(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 3})
+
+	for i := 0; i < 20; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	modified := tree.SetConfig(Config{MaxDegree: 5, RootDegree: 5})
+	if len(modified) != 0 {
+		t.Errorf("Expected no nodes to be modified when loosening the config, but got %v", modified)
+	}
+}
+
+type capacityValue struct {
+	children int
+}
+
+func (c capacityValue) DeclaredCapacity() Capacity {
+	if c.children < 0 {
+		return Capacity{}
+	}
+	return Capacity{Children: maybe.Something(c.children)}
+}
+
+func TestTreeDeclaredCapacity(t *testing.T) {
+	tree := New[string, capacityValue](Config{MaxDegree: 4, RootDegree: 4})
+
+	tree.Upsert("root", capacityValue{1})
+	tree.Upsert("relay", capacityValue{-1})
+	for i := 0; i < 3; i++ {
+		tree.Upsert(fmt.Sprintf("leaf%d", i), capacityValue{0})
+	}
+
+	list := tree.AdjacencyList()
+	if !list["root"].Neighbors.Equals(set.New("relay")) {
+		t.Errorf("Expected the root to only hold the relay, but got %v", list["root"].Neighbors)
+	}
+
+	for i := 0; i < 3; i++ {
+		key := fmt.Sprintf("leaf%d", i)
+		if len(list[key].Neighbors) != 1 {
+			t.Errorf("Expected %s to be a leaf, but it has %d neighbors", key, len(list[key].Neighbors))
+		}
+	}
+}
+
+func TestTreeLowerCapacity(t *testing.T) {
+	config := Config{MaxDegree: 4, RootDegree: 4}
+	tree := New[string, capacityValue](config)
+
+	for i := 0; i < 30; i++ {
+		tree.Upsert(fmt.Sprint(i), capacityValue{-1})
+	}
+
+	before := tree.AdjacencyList()
+	if len(before["1"].Neighbors) <= 1 {
+		t.Fatal("Expected node 1 to have children to begin with")
+	}
+
+	modified := tree.Upsert("1", capacityValue{0})
+
+	list := tree.AdjacencyList()
+	if len(list["1"].Neighbors) != 1 {
+		t.Errorf("Expected node 1 to have become a leaf, but it has %d neighbors", len(list["1"].Neighbors))
+	}
+
+	if len(list) != 30 {
+		t.Errorf("Expected the tree to still have 30 nodes, but got %d", len(list))
+	}
+
+	checkDegrees(t, list, "0", config)
+
+	if !IsTree(list) {
+		t.Error("Expected the graph to be a tree, but ended up not being a tree")
+	}
+
+	for key, node := range list {
+		if !node.Neighbors.Equals(before[key].Neighbors) && !modified.Has(key) {
+			t.Errorf("Node %s had its neighbors changed, but was not reported as modified", key)
+		}
+	}
+}
+
+func TestTreeNoRoom(t *testing.T) {
+	for _, placement := range []string{PlacementShortestSubtree, PlacementBreadthFirst, PlacementRandom} {
+		tree := New[string, capacityValue](Config{MaxDegree: 4, RootDegree: 4, Placement: placement})
+
+		// Everyone is full: the root holds all it declared room for, and its
+		// only child declared no room at all. With the root being the source,
+		// nobody may take over as the root either
+		tree.SetSource("a")
+		tree.Upsert("a", capacityValue{1})
+		tree.Upsert("b", capacityValue{0})
+		if tree.HasVacancy("c", capacityValue{-1}) {
+			t.Errorf("%s: Expected the tree to have no room to spare", placement)
+		}
+
+		tree.Upsert("c", capacityValue{-1})
+		if tree.Has("c") || tree.Len() != 2 {
+			t.Errorf("%s: Expected c to not have been placed, but the tree has %v", placement, tree.AdjacencyList())
+		}
+		if evicted := tree.TakeEvicted(); len(evicted) != 1 || evicted[0].Key != "c" {
+			t.Errorf("%s: Expected c to have been evicted, but got %v", placement, evicted)
+		}
+		if evicted := tree.TakeEvicted(); len(evicted) != 0 {
+			t.Errorf("%s: Expected the evicted nodes to only be taken once, but got %v", placement, evicted)
+		}
+
+		// Lowering the root's capacity leaves no room for the subtree of b
+		tree.Upsert("b", capacityValue{-1})
+		tree.Upsert("c", capacityValue{-1})
+		tree.Upsert("a", capacityValue{0})
+		if tree.Len() != 1 {
+			t.Errorf("%s: Expected only the root to be left, but the tree has %v", placement, tree.AdjacencyList())
+		}
+		evicted := tree.TakeEvicted()
+		if len(evicted) != 2 || evicted[0].Key != "b" || evicted[1].Key != "c" {
+			t.Errorf("%s: Expected b and then c to have been evicted, but got %v", placement, evicted)
+		}
+		if violations := tree.Validate(); len(violations) > 0 {
+			t.Errorf("%s: Expected no violations, but got %v", placement, violations)
+		}
+	}
+}
+
+func TestTreeNoRoomSuspended(t *testing.T) {
+	tree := New[string, int](DefaultConfig())
+
+	tree.SetSource("a")
+	tree.Upsert("a", 1)
+	tree.Suspend("a")
+
+	tree.Upsert("b", 2)
+	if tree.Has("b") {
+		t.Error("Expected b to not have been placed under the suspended root")
+	}
+	if evicted := tree.TakeEvicted(); len(evicted) != 1 || evicted[0].Key != "b" {
+		t.Errorf("Expected b to have been evicted, but got %v", evicted)
+	}
+
+	tree.Resume("a")
+	if !tree.HasVacancy("b", 2) {
+		t.Error("Expected the resumed root to have room to spare")
+	}
+}
+
+func TestTreeZeroCapacityRoot(t *testing.T) {
+	for _, placement := range []string{PlacementShortestSubtree, PlacementBreadthFirst, PlacementRandom} {
+		tree := New[string, capacityValue](Config{MaxDegree: 3, RootDegree: 2, Placement: placement})
+
+		// The first to join, such as a viewer, declared no room at all
+		tree.Upsert("viewer", capacityValue{0})
+		if !tree.HasVacancy("publisher", capacityValue{-1}) {
+			t.Errorf("%s: Expected a node with room to have room in the tree", placement)
+		}
+		if tree.HasVacancy("other", capacityValue{0}) {
+			t.Errorf("%s: Expected a node without room to have no room in the tree", placement)
+		}
+
+		// A newcomer with room takes over as the root, rather than being evicted
+		modified := tree.Upsert("publisher", capacityValue{-1})
+		if evicted := tree.TakeEvicted(); len(evicted) != 0 {
+			t.Errorf("%s: Expected nobody to have been evicted, but got %v", placement, evicted)
+		}
+		if root, _ := tree.Root().Get(); root.Key != "publisher" {
+			t.Errorf("%s: Expected publisher to have become the root, but got %s", placement, root.Key)
+		}
+		if !modified.Equals(set.New("viewer", "publisher")) {
+			t.Errorf("%s: Expected viewer and publisher to have been modified, but got %v", placement, modified)
+		}
+
+		// Everyone after that is placed as usual
+		tree.Upsert("other", capacityValue{-1})
+		if position, ok := tree.GetPositionOfNode("other"); !ok || position.Depth != 1 {
+			t.Errorf("%s: Expected other to have been placed under the new root, but got %v", placement, tree.AdjacencyList())
+		}
+		if violations := tree.Validate(); len(violations) > 0 {
+			t.Errorf("%s: Expected no violations, but got %v", placement, violations)
+		}
+	}
+}
+
+func TestTreeUpsertExisting(t *testing.T) {
+	tree := New[string, int](DefaultConfig())
+
+	for i := 0; i < 10; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+	tree.Upsert("7", 42)
+
+	list := tree.AdjacencyList()
+	if len(list) != 10 {
+		t.Errorf("Expected the tree to still have 10 nodes, but got %d", len(list))
+	}
+	if list["7"].Value != 42 {
+		t.Errorf("Expected node 7 to have the value 42, but got %d", list["7"].Value)
+	}
+}
+
+func TestTreeDeleteInterior(t *testing.T) {
+	tree := New[string, int](DefaultConfig())
+
+	for i := 0; i < 30; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	tree.DeleteByKey("1")
+	tree.DeleteByKey("0")
+
+	list := tree.AdjacencyList()
+	if len(list) != 28 {
+		t.Errorf("Expected the tree to have 28 nodes, but got %d", len(list))
+	}
+
+	if !IsTree(list) {
+		t.Error("Expected the graph to be a tree, but ended up not being a tree")
+	}
+}
+
+func TestTreeSourceJoinsLate(t *testing.T) {
+	config := Config{MaxDegree: 3, RootDegree: 2}
+	tree := New[string, int](config)
+	tree.SetSource("source")
+
+	for i := 0; i < 10; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+
+	modified := tree.Upsert("source", 0)
+	if len(modified) != 11 {
+		t.Errorf("Expected every node to have been modified, but got %v", modified)
+	}
+
+	position, ok := tree.GetPositionOfNode("source")
+	if !ok {
+		t.Fatal("Expected the source to be in the tree")
+	}
+	if _, ok := position.Parent.Get(); ok || position.Depth != 0 {
+		t.Error("Expected the source to be the root of the tree")
+	}
+
+	checkDegrees(t, tree.AdjacencyList(), "source", config)
+}
+
+func TestTreeSourceIsPinned(t *testing.T) {
+	tree := New[string, int](DefaultConfig())
+
+	for i := 0; i < 10; i++ {
+		tree.Upsert(fmt.Sprint(i), i)
+	}
+	tree.SetSource("5")
+
+	for i := 0; i < 10; i++ {
+		if i == 5 {
+			continue
+		}
+		tree.DeleteByKey(fmt.Sprint(i))
+		tree.Upsert(fmt.Sprint(i+10), i)
+
+		position, ok := tree.GetPositionOfNode("5")
+		if !ok {
+			t.Fatal("Expected the source to be in the tree")
+		}
+		if _, ok := position.Parent.Get(); ok {
+			t.Fatalf("Expected the source to remain the root after deleting %d", i)
+		}
+	}
+
+	if !IsTree(tree.AdjacencyList()) {
+		t.Error("Expected the graph to be a tree, but ended up not being a tree")
+	}
+}
+
+func TestTreeSourceReleased(t *testing.T) {
+	tree := New[string, int](DefaultConfig())
+	tree.SetSource("a")
+	tree.Upsert("a", 1)
+	tree.Upsert("b", 2)
+
+	// A suspended source keeps its claim
+	tree.Suspend("a")
+	if source, ok := tree.Source().Get(); !ok || source != "a" {
+		t.Error("Expected the suspended source to still be the source")
+	}
+
+	tree.DeleteByKey("a")
+	if tree.IsRooted() {
+		t.Error("Expected the tree to no longer be rooted once the source was deleted")
+	}
+
+	// Anyone else may now become the source
+	tree.SetSource("b")
+	if position, _ := tree.GetPositionOfNode("b"); position.Depth != 0 || !tree.IsRooted() {
+		t.Errorf("Expected b to have become the source, but got %v", position)
+	}
+}
+
+func TestTreePosition(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 2, RootDegree: 1})
+	tree.SetSource("a")
+
+	tree.Upsert("a", 1)
+	tree.Upsert("b", 2)
+	tree.Upsert("c", 3)
+
+	position, ok := tree.GetPositionOfNode("b")
+	if !ok {
+		t.Fatal("Expected b to be in the tree")
+	}
+
+	parent, ok := position.Parent.Get()
+	if !ok || parent.Key != "a" {
+		t.Errorf("Expected the parent of b to be a, but got %v", position.Parent)
+	}
+	if len(position.Children) != 1 || position.Children[0].Key != "c" {
+		t.Errorf("Expected the only child of b to be c, but got %v", position.Children)
+	}
+	if position.Depth != 1 {
+		t.Errorf("Expected b to have a depth of 1, but got %d", position.Depth)
+	}
+}
+
+func TestTreeSuspend(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 2, RootDegree: 2})
+
+	tree.Upsert("a", 1)
+	tree.Upsert("b", 2)
+	tree.Upsert("c", 3)
+
+	modified := tree.Suspend("b")
+	if !modified.Equals(set.New("a", "b")) {
+		t.Errorf("Expected a and b to be modified, but got %v", modified)
+	}
+
+	neighbors, _ := tree.GetNeighborsOfNode("a")
+	for _, neighbor := range neighbors {
+		if neighbor.Suspended != (neighbor.Key == "b") {
+			t.Errorf("Expected only b to be suspended, but got %v", neighbors)
+		}
+	}
+
+	// Both b and c are able to take on a child, but b is suspended
+	tree.Upsert("d", 4)
+	position, _ := tree.GetPositionOfNode("d")
+	if parent, _ := position.Parent.Get(); parent.Key != "c" {
+		t.Errorf("Expected d to have been placed under c, but got %v", parent.Key)
+	}
+
+	tree.Resume("b")
+	neighbors, _ = tree.GetNeighborsOfNode("a")
+	for _, neighbor := range neighbors {
+		if neighbor.Suspended {
+			t.Errorf("Expected b to no longer be suspended, but got %v", neighbors)
+		}
+	}
+}
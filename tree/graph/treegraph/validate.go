@@ -0,0 +1,128 @@
+package treegraph
+
+import (
+	"fmt"
+	"tree/graph/adjacencylist"
+	"tree/graph/graph"
+	"tree/graph/set"
+)
+
+const (
+	// ViolationUnindexed is a node in the tree that the tree's key index is
+	// unaware of, or that the index has an outdated copy of
+	ViolationUnindexed adjacencylist.ViolationKind = "unindexed"
+
+	// ViolationSourceNotRoot is a source that is in the tree, but that is not at
+	// the root
+	ViolationSourceNotRoot adjacencylist.ViolationKind = "source-not-root"
+)
+
+// Validate checks that the tree is still a tree, and that it is shaped the way
+// its config says it should be. That is, parents and children agree on the
+// edges between them, there are no cycles, every node is reachable from the
+// root, no node has more neighbors than the config permits, every node is
+// indexed, and the source, if present, is at the root. Capacity that nodes
+// declare for themselves is not checked
+func (t Tree[K, V]) Validate() adjacencylist.Violations[K] {
+	violations := adjacencylist.Violations[K]{}
+
+	root, ok := t.maybeRoot.Get()
+	if !ok {
+		for key := range t.nodes {
+			violations = append(violations, adjacencylist.Violation[K]{
+				Kind:   adjacencylist.ViolationDisconnected,
+				Key:    key,
+				Detail: "is indexed, but the tree is empty",
+			})
+		}
+		return violations
+	}
+
+	if root.Parent != nil {
+		violations = append(violations, adjacencylist.Violation[K]{
+			Kind:   adjacencylist.ViolationAsymmetricEdge,
+			Key:    root.Key,
+			Detail: fmt.Sprintf("is the root, but has %v as its parent", root.Parent.Key),
+		})
+	}
+
+	// Walk the tree by hand, rather than via BreadthFirst, since a cycle would
+	// otherwise send the walk around in circles
+	visited := set.New(root.Key)
+	order := []*graph.RootedNode[K, V]{root}
+	for i := 0; i < len(order); i++ {
+		node := order[i]
+		violations = append(violations, t.validateNode(node, node == root)...)
+
+		for _, child := range node.Children {
+			if visited.Has(child.Key) {
+				violations = append(violations, adjacencylist.Violation[K]{
+					Kind:   adjacencylist.ViolationCycle,
+					Key:    child.Key,
+					Detail: fmt.Sprintf("is reachable from %v via more than one path", node.Key),
+				})
+				continue
+			}
+			visited.Add(child.Key)
+			order = append(order, child)
+		}
+	}
+
+	for key := range t.nodes {
+		if !visited.Has(key) {
+			violations = append(violations, adjacencylist.Violation[K]{
+				Kind:   adjacencylist.ViolationDisconnected,
+				Key:    key,
+				Detail: fmt.Sprintf("is indexed, but is unreachable from the root %v", root.Key),
+			})
+		}
+	}
+
+	if source, ok := t.maybeSource.Get(); ok && t.Has(source) && source != root.Key {
+		violations = append(violations, adjacencylist.Violation[K]{
+			Kind:   ViolationSourceNotRoot,
+			Key:    source,
+			Detail: fmt.Sprintf("is the source, but %v is the root", root.Key),
+		})
+	}
+
+	return violations
+}
+
+// validateNode checks the edges, the degree and the indexing of a single node
+func (t Tree[K, V]) validateNode(node *graph.RootedNode[K, V], isRoot bool) adjacencylist.Violations[K] {
+	violations := adjacencylist.Violations[K]{}
+
+	for _, child := range node.Children {
+		if child.Parent != node {
+			violations = append(violations, adjacencylist.Violation[K]{
+				Kind:   adjacencylist.ViolationAsymmetricEdge,
+				Key:    node.Key,
+				Detail: fmt.Sprintf("has %v as a child, which has a different parent", child.Key),
+			})
+		}
+	}
+
+	config := t.Config()
+	limit := config.MaxDegree
+	if isRoot {
+		limit = config.RootDegree
+	}
+	if degree := len(node.Neighbors()); degree > limit {
+		violations = append(violations, adjacencylist.Violation[K]{
+			Kind:   adjacencylist.ViolationDegree,
+			Key:    node.Key,
+			Detail: fmt.Sprintf("has %d neighbors, but at most %d are permitted", degree, limit),
+		})
+	}
+
+	if indexed, ok := t.find(node.Key); !ok || indexed != node {
+		violations = append(violations, adjacencylist.Violation[K]{
+			Kind:   ViolationUnindexed,
+			Key:    node.Key,
+			Detail: "is in the tree, but is not in the index",
+		})
+	}
+
+	return violations
+}
@@ -0,0 +1,140 @@
+package treegraph
+
+import (
+	"fmt"
+	"math/rand"
+	"slices"
+	"testing"
+	"tree/graph/graph"
+)
+
+// drive runs a random sequence of joins, leaves, capacity changes and
+// suspensions against the tree, and validates the tree after every step. Nodes
+// that get evicted are taken to have left
+func drive(t *testing.T, tree *Tree[string, capacityValue], seed int64, steps int) {
+	r := rand.New(rand.NewSource(seed))
+	keys := []string{}
+
+	randomValue := func() capacityValue {
+		if r.Intn(3) == 0 {
+			return capacityValue{r.Intn(4)}
+		}
+		return capacityValue{-1}
+	}
+
+	for step := 0; step < steps; step++ {
+		var description string
+		switch op := r.Intn(10); {
+		case op < 5 || len(keys) <= 0:
+			key := fmt.Sprint(step)
+			keys = append(keys, key)
+			description = "upsert " + key
+			tree.Upsert(key, randomValue())
+		case op < 8:
+			i := r.Intn(len(keys))
+			description = "delete " + keys[i]
+			tree.DeleteByKey(keys[i])
+			keys = append(keys[:i], keys[i+1:]...)
+		case op < 9:
+			key := keys[r.Intn(len(keys))]
+			description = "update " + key
+			tree.Upsert(key, randomValue())
+		default:
+			key := keys[r.Intn(len(keys))]
+			if tree.IsSuspended(key) {
+				description = "resume " + key
+				tree.Resume(key)
+			} else {
+				description = "suspend " + key
+				tree.Suspend(key)
+			}
+		}
+
+		for _, evicted := range tree.TakeEvicted() {
+			keys = slices.DeleteFunc(keys, func(key string) bool { return key == evicted.Key })
+		}
+
+		if violations := tree.Validate(); len(violations) > 0 {
+			t.Fatalf("After step %d (%s): %v", step, description, violations)
+		}
+
+		for _, key := range keys {
+			node, _ := tree.find(key)
+			if len(node.Children) > tree.ChildLimit(node) {
+				t.Fatalf("After step %d (%s): %s has %d children, but declared room for %d", step, description, key, len(node.Children), tree.ChildLimit(node))
+			}
+		}
+
+		config := tree.Config()
+		limit := config.MaxDegree
+		if config.RootDegree > limit {
+			limit = config.RootDegree
+		}
+		if violations := tree.AdjacencyList().Validate(limit); len(violations) > 0 {
+			t.Fatalf("After step %d (%s): %v", step, description, violations)
+		}
+
+		if len(tree.AdjacencyList()) != len(keys) {
+			t.Fatalf("After step %d (%s): expected %d nodes, but got %d", step, description, len(keys), len(tree.AdjacencyList()))
+		}
+	}
+}
+
+func TestRandomOperationsKeepTree(t *testing.T) {
+	placements := []string{PlacementShortestSubtree, PlacementBreadthFirst, PlacementRandom}
+	deletions := []string{DeletionReplace, DeletionPromote, DeletionReattach}
+
+	// The root is permitted a number of children of its own, which may be
+	// fewer, as many, or more than any other node is permitted
+	r := rand.New(rand.NewSource(7))
+	for i := 0; i < 6; i++ {
+		maxDegree := 2 + r.Intn(4)
+		rootDegree := 1 + r.Intn(5)
+
+		for _, placement := range placements {
+			for _, deletion := range deletions {
+				for _, rooted := range []bool{false, true} {
+					tree := New[string, capacityValue](Config{
+						MaxDegree:  maxDegree,
+						RootDegree: rootDegree,
+						Placement:  placement,
+						Deletion:   deletion,
+						Seed:       1,
+					})
+					if rooted {
+						// The source joins part way through
+						tree.SetSource("50")
+					}
+					drive(t, &tree, int64(42+i), 300)
+				}
+			}
+		}
+	}
+}
+
+func TestValidateFindsViolations(t *testing.T) {
+	tree := New[string, int](Config{MaxDegree: 3, RootDegree: 1})
+	tree.Upsert("a", 1)
+	tree.Upsert("b", 2)
+	tree.Upsert("c", 3)
+
+	if violations := tree.Validate(); len(violations) > 0 {
+		t.Fatalf("Expected no violations, but got %v", violations)
+	}
+
+	a, _ := tree.find("a")
+	c, _ := tree.find("c")
+
+	// Have c claim a child that does not claim c as its parent
+	c.Children = append(c.Children, a)
+	if violations := tree.Validate(); !violations.Has("asymmetric-edge") || !violations.Has("cycle") {
+		t.Errorf("Expected an asymmetric edge and a cycle, but got %v", violations)
+	}
+	c.Children = c.Children[:0]
+
+	// Exceed the root degree, with a node that is not indexed either
+	a.AddChild(graph.NewRootedNode("d", 4))
+	if violations := tree.Validate(); !violations.Has("degree") || !violations.Has(ViolationUnindexed) {
+		t.Errorf("Expected a degree violation and an unindexed node, but got %v", violations)
+	}
+}
@@ -1,98 +1,119 @@
 package listeners
 
-import "sync"
+import (
+	"iter"
+	"sync"
+)
 
-type KeyedListeners struct {
+// KeyedListeners holds a separate stream of events of type T per key
+type KeyedListeners[K comparable, T any] struct {
 	mut       *sync.RWMutex
-	listeners map[interface{}]*Listeners
+	listeners map[K]*Listeners[T]
 }
 
-func NewKeyedListeners() KeyedListeners {
+func NewKeyedListeners[K comparable, T any]() KeyedListeners[K, T] {
 	mut := &sync.RWMutex{}
-	return KeyedListeners{
+	return KeyedListeners[K, T]{
 		mut:       mut,
-		listeners: make(map[interface{}]*Listeners),
+		listeners: make(map[K]*Listeners[T]),
 	}
 }
 
-func (k *KeyedListeners) RegisterListener(key interface{}) <-chan interface{} {
+// Subscribe creates a subscription to all events emitted for the key from now
+// on
+func (k *KeyedListeners[K, T]) Subscribe(key K, options Options[T]) *Subscription[T] {
 	k.mut.Lock()
 	defer k.mut.Unlock()
 
-	k.initializeListeners()
-
 	listeners, ok := k.listeners[key]
 	if !ok {
-		listeners = &Listeners{}
+		listeners = NewListeners[T]()
 		k.listeners[key] = listeners
 	}
-	return listeners.RegisterListener()
+	return listeners.Subscribe(options)
 }
 
-func (k *KeyedListeners) initializeListeners() {
-	if k.listeners == nil {
-		k.listeners = make(map[interface{}]*Listeners)
+// Unsubscribe ends the subscription to the events for the key, dropping the key
+// altogether once it is left without any subscribers
+func (k *KeyedListeners[K, T]) Unsubscribe(key K, s *Subscription[T]) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
+
+	listeners, ok := k.listeners[key]
+	if !ok {
+		s.close()
+		return
+	}
+
+	listeners.Unsubscribe(s)
+	if listeners.Len() <= 0 {
+		delete(k.listeners, key)
 	}
 }
 
-func (k *KeyedListeners) UnregisterListener(key interface{}, c <-chan interface{}) {
+// Close ends every subscription to the events for the key, and drops the key
+func (k *KeyedListeners[K, T]) Close(key K) {
 	k.mut.Lock()
 	defer k.mut.Unlock()
 
-	k.initializeListeners()
-
 	listeners, ok := k.listeners[key]
 	if !ok {
 		return
 	}
 
-	listeners.UnregisterListener(c)
+	listeners.Close()
+	delete(k.listeners, key)
 }
 
-func (k *KeyedListeners) EmitEvent(key, d interface{}) {
-	k.mut.RLock()
-	defer k.mut.RUnlock()
+// Emit queues up the event for every subscriber to the key
+func (k *KeyedListeners[K, T]) Emit(key K, event T) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
 
-	listeners, ok := k.listeners[key]
-	if ok {
-		listeners.EmitEvent(d)
+	if listeners, ok := k.listeners[key]; ok {
+		k.emit(key, listeners, event)
 	}
 }
 
-func (k *KeyedListeners) EmitEventToAll(d interface{}) {
-	k.mut.RLock()
-	defer k.mut.RUnlock()
+// EmitToAll queues up the event for every subscriber to every key
+func (k *KeyedListeners[K, T]) EmitToAll(event T) {
+	k.mut.Lock()
+	defer k.mut.Unlock()
 
-	for _, value := range k.listeners {
-		value.EmitEvent(d)
+	for key, listeners := range k.listeners {
+		k.emit(key, listeners, event)
 	}
 }
 
-type Pair struct {
-	key       interface{}
-	listeners *Listeners
+// emit queues up the event for the listeners of the key, dropping the key
+// should all of its subscribers have been disconnected for falling behind. The
+// caller is expected to already be holding the lock
+func (k *KeyedListeners[K, T]) emit(key K, listeners *Listeners[T], event T) {
+	listeners.Emit(event)
+	if listeners.Len() <= 0 {
+		delete(k.listeners, key)
+	}
 }
 
-func (k *KeyedListeners) Iterate() <-chan Pair {
-	c := make(chan Pair)
-
-	go func() {
+// All iterates through every key, along with its listeners. The listeners are
+// read-locked for as long as the iteration is underway, so the loop must not
+// subscribe or unsubscribe
+func (k *KeyedListeners[K, T]) All() iter.Seq2[K, *Listeners[T]] {
+	return func(yield func(K, *Listeners[T]) bool) {
 		k.mut.RLock()
 		defer k.mut.RUnlock()
 
-		for key, value := range k.listeners {
-			c <- Pair{key, value}
+		for key, listeners := range k.listeners {
+			if !yield(key, listeners) {
+				return
+			}
 		}
-		close(c)
-	}()
-
-	return c
+	}
 }
 
-func (k *KeyedListeners) Get(key interface{}) (*Listeners, bool) {
+// Len gets the number of keys that have subscribers
+func (k *KeyedListeners[K, T]) Len() int {
 	k.mut.RLock()
 	defer k.mut.RUnlock()
-
-	l, ok := k.listeners[key]
-	return l, ok
+	return len(k.listeners)
 }
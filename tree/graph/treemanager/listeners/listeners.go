@@ -4,42 +4,236 @@ import (
 	"sync"
 )
 
-type Listeners struct {
-	mut       sync.RWMutex
-	listeners [](chan interface{})
+// Policy decides what happens to a subscriber whose queue is full, because it
+// is not keeping up with the events being emitted
+type Policy string
+
+const (
+	// PolicyCoalesce merges the new event into the newest event in the queue,
+	// via the Coalesce function of the options
+	PolicyCoalesce Policy = "coalesce"
+
+	// PolicyDropOldest drops the oldest event in the queue, to make room for the
+	// new event
+	PolicyDropOldest Policy = "drop-oldest"
+
+	// PolicyDisconnect unsubscribes the subscriber, closing its channel
+	PolicyDisconnect Policy = "disconnect"
+)
+
+// DefaultQueueSize is the number of events that a subscriber's queue holds,
+// when no other size has been supplied
+const DefaultQueueSize = 16
+
+// Options governs how events get delivered to a subscriber
+type Options[T any] struct {
+	// QueueSize is the number of events that may be waiting to be delivered to
+	// the subscriber. Values smaller than 1 are treated as DefaultQueueSize
+	QueueSize int
+
+	// Policy is what happens once the queue is full. Unknown policies are
+	// treated as PolicyDropOldest
+	Policy Policy
+
+	// Coalesce merges an older event with a newer event, for PolicyCoalesce.
+	// Without it, the newer event simply replaces the older one
+	Coalesce func(older, newer T) T
+}
+
+// Subscription is a single subscriber's view of the events being emitted.
+//
+// Events are queued up by the emitter, and delivered in order, by a single
+// goroutine per subscription, so that emitting never blocks on a slow
+// subscriber
+type Subscription[T any] struct {
+	options Options[T]
+	events  chan T
+
+	mut    sync.Mutex
+	queue  []T
+	closed bool
+
+	// notify is signalled whenever an event has been queued up, and done is
+	// closed once the subscription has ended
+	notify chan struct{}
+	done   chan struct{}
+}
+
+func newSubscription[T any](options Options[T]) *Subscription[T] {
+	if options.QueueSize < 1 {
+		options.QueueSize = DefaultQueueSize
+	}
+
+	s := &Subscription[T]{
+		options: options,
+		events:  make(chan T),
+		queue:   []T{},
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	go s.deliver()
+
+	return s
+}
+
+// Events gets the channel that the events get delivered on, in the order in
+// which they were emitted. The channel is closed once the subscription has
+// ended, be it by unsubscribing, or by being disconnected for falling behind
+func (s *Subscription[T]) Events() <-chan T {
+	return s.events
+}
+
+// push queues up the event, applying the policy should the queue be full.
+// Returns whether the subscription is still going
+func (s *Subscription[T]) push(event T) bool {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	if s.closed {
+		return false
+	}
+
+	if len(s.queue) >= s.options.QueueSize {
+		switch s.options.Policy {
+		case PolicyDisconnect:
+			s.end()
+			return false
+		case PolicyCoalesce:
+			last := len(s.queue) - 1
+			if s.options.Coalesce != nil {
+				event = s.options.Coalesce(s.queue[last], event)
+			}
+			s.queue[last] = event
+			return true
+		default:
+			s.queue = s.queue[1:]
+		}
+	}
+
+	s.queue = append(s.queue, event)
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+
+	return true
 }
 
-func (l *Listeners) RegisterListener() <-chan interface{} {
+// close ends the subscription
+func (s *Subscription[T]) close() {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.end()
+}
+
+// end ends the subscription. The caller is expected to already be holding the
+// lock
+func (s *Subscription[T]) end() {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	s.queue = nil
+	close(s.done)
+}
+
+// deliver hands the queued up events to the subscriber, one at a time, until
+// the subscription ends. Since this is the only goroutine that ever sends on
+// the events channel, it is also the one to close it
+func (s *Subscription[T]) deliver() {
+	defer close(s.events)
+
+	for {
+		s.mut.Lock()
+		if s.closed {
+			s.mut.Unlock()
+			return
+		}
+		if len(s.queue) <= 0 {
+			s.mut.Unlock()
+			select {
+			case <-s.notify:
+				continue
+			case <-s.done:
+				return
+			}
+		}
+		event := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mut.Unlock()
+
+		select {
+		case s.events <- event:
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// Listeners holds the subscriptions to a single stream of events of type T
+type Listeners[T any] struct {
+	mut           sync.RWMutex
+	subscriptions map[*Subscription[T]]bool
+}
+
+// NewListeners creates a stream of events without any subscribers
+func NewListeners[T any]() *Listeners[T] {
+	return &Listeners[T]{subscriptions: map[*Subscription[T]]bool{}}
+}
+
+// Subscribe creates a subscription to all events emitted from now on
+func (l *Listeners[T]) Subscribe(options Options[T]) *Subscription[T] {
 	l.mut.Lock()
 	defer l.mut.Unlock()
-	c := make(chan interface{})
-	l.listeners = append(l.listeners, c)
-	return c
+
+	if l.subscriptions == nil {
+		l.subscriptions = map[*Subscription[T]]bool{}
+	}
+
+	s := newSubscription(options)
+	l.subscriptions[s] = true
+	return s
 }
 
-func (l *Listeners) UnregisterListener(c <-chan interface{}) {
+// Unsubscribe ends the subscription. This is safe to call at any time, even
+// while events are being emitted, and more than once
+func (l *Listeners[T]) Unsubscribe(s *Subscription[T]) {
 	l.mut.Lock()
 	defer l.mut.Unlock()
 
-	listeners := make([](chan interface{}), 0)
+	delete(l.subscriptions, s)
+	s.close()
+}
+
+// Emit queues up the event for every subscriber, without waiting for any of
+// them to receive it. Subscribers that have been disconnected for falling
+// behind are dropped
+func (l *Listeners[T]) Emit(event T) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
 
-	for _, listener := range l.listeners {
-		if listener != c {
-			listeners = append(listeners, listener)
-		} else {
-			close(listener)
+	for s := range l.subscriptions {
+		if !s.push(event) {
+			delete(l.subscriptions, s)
 		}
 	}
-	l.listeners = listeners
 }
 
-func (l *Listeners) EmitEvent(d interface{}) {
-	l.mut.RLock()
-	defer l.mut.RUnlock()
+// Close ends every subscription
+func (l *Listeners[T]) Close() {
+	l.mut.Lock()
+	defer l.mut.Unlock()
 
-	for _, listener := range l.listeners {
-		go func(l chan interface{}) {
-			l <- d
-		}(listener)
+	for s := range l.subscriptions {
+		s.close()
+		delete(l.subscriptions, s)
 	}
 }
+
+// Len gets the number of subscribers
+func (l *Listeners[T]) Len() int {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	return len(l.subscriptions)
+}
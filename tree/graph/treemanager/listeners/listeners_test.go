@@ -0,0 +1,164 @@
+package listeners
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+// receive gets the next event, failing the test should none arrive in time
+func receive[T any](t *testing.T, s *Subscription[T]) (T, bool) {
+	t.Helper()
+	select {
+	case event, ok := <-s.Events():
+		return event, ok
+	case <-time.After(time.Second):
+		t.Fatal("Timed out waiting for an event")
+	}
+	var zero T
+	return zero, false
+}
+
+// waitForQueue waits for the subscription to have the supplied number of
+// events queued up, with one more held by the delivering goroutine
+func waitForQueue[T any](s *Subscription[T], length int) {
+	for {
+		s.mut.Lock()
+		n := len(s.queue)
+		s.mut.Unlock()
+		if n == length {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestInOrderDelivery(t *testing.T) {
+	l := NewListeners[int]()
+	s := l.Subscribe(Options[int]{QueueSize: 1000})
+
+	for i := 0; i < 1000; i++ {
+		l.Emit(i)
+	}
+
+	for i := 0; i < 1000; i++ {
+		if event, _ := receive(t, s); event != i {
+			t.Fatalf("Expected event %d, but got %d", i, event)
+		}
+	}
+}
+
+func TestDropOldest(t *testing.T) {
+	l := NewListeners[int]()
+	s := l.Subscribe(Options[int]{QueueSize: 2, Policy: PolicyDropOldest})
+
+	// The first event is held by the delivering goroutine, rather than queued
+	l.Emit(0)
+	waitForQueue(s, 0)
+	for i := 1; i <= 4; i++ {
+		l.Emit(i)
+	}
+
+	for _, expected := range []int{0, 3, 4} {
+		if event, _ := receive(t, s); event != expected {
+			t.Errorf("Expected event %d, but got %d", expected, event)
+		}
+	}
+}
+
+func TestCoalesce(t *testing.T) {
+	l := NewListeners[int]()
+	s := l.Subscribe(Options[int]{
+		QueueSize: 2,
+		Policy:    PolicyCoalesce,
+		Coalesce:  func(older, newer int) int { return older + newer },
+	})
+
+	l.Emit(0)
+	waitForQueue(s, 0)
+	for i := 1; i <= 4; i++ {
+		l.Emit(i)
+	}
+
+	for _, expected := range []int{0, 1, 2 + 3 + 4} {
+		if event, _ := receive(t, s); event != expected {
+			t.Errorf("Expected event %d, but got %d", expected, event)
+		}
+	}
+}
+
+func TestDisconnect(t *testing.T) {
+	l := NewListeners[int]()
+	s := l.Subscribe(Options[int]{QueueSize: 1, Policy: PolicyDisconnect})
+
+	l.Emit(0)
+	waitForQueue(s, 0)
+	l.Emit(1)
+	l.Emit(2)
+
+	// Whatever was in flight may or may not make it, but the channel must close
+	for {
+		if _, ok := receive(t, s); !ok {
+			break
+		}
+	}
+
+	if l.Len() != 0 {
+		t.Errorf("Expected the disconnected subscriber to be dropped, but got %d subscribers", l.Len())
+	}
+}
+
+func TestUnsubscribeWhileEmitting(t *testing.T) {
+	l := NewListeners[int]()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				l.Emit(j)
+			}
+		}()
+	}
+
+	for i := 0; i < 100; i++ {
+		s := l.Subscribe(Options[int]{QueueSize: 1})
+		l.Unsubscribe(s)
+		l.Unsubscribe(s)
+		for range s.Events() {
+		}
+	}
+
+	wg.Wait()
+}
+
+func TestKeyedListenersDropEmptyKeys(t *testing.T) {
+	k := NewKeyedListeners[string, int]()
+
+	a := k.Subscribe("a", Options[int]{})
+	b := k.Subscribe("a", Options[int]{})
+	k.Subscribe("b", Options[int]{QueueSize: 1, Policy: PolicyDisconnect})
+
+	k.Emit("a", 1)
+	if event, _ := receive(t, a); event != 1 {
+		t.Errorf("Expected event 1, but got %d", event)
+	}
+
+	k.Unsubscribe("a", a)
+	if k.Len() != 2 {
+		t.Errorf("Expected a to still have a subscriber, but got %d keys", k.Len())
+	}
+	k.Unsubscribe("a", b)
+	if k.Len() != 1 {
+		t.Errorf("Expected a to have been dropped, but got %d keys", k.Len())
+	}
+
+	// Nobody is receiving the events for b, which eventually disconnects
+	for i := 0; i < 3; i++ {
+		k.Emit("b", i)
+	}
+	if k.Len() != 0 {
+		t.Errorf("Expected b to have been dropped, but got %d keys", k.Len())
+	}
+}
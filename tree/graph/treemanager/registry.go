@@ -0,0 +1,218 @@
+package treemanager
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager/listeners"
+)
+
+// ErrCreationForbidden is returned when joining a tree that does not exist, and
+// that the creation policy does not permit to be created by joining it
+var ErrCreationForbidden = errors.New("tree may not be created")
+
+// ErrTreeExists is returned when creating a tree that already exists
+var ErrTreeExists = errors.New("tree already exists")
+
+// CreationPolicy decides which trees get created by participants joining them.
+// Trees can always be created via CreateTree, regardless of the policy
+type CreationPolicy string
+
+const (
+	// CreateOnJoin creates any tree as soon as its first participant joins
+	CreateOnJoin CreationPolicy = "auto"
+
+	// CreateByAdmin only has trees created via CreateTree
+	CreateByAdmin CreationPolicy = "admin"
+
+	// CreateByPrefix creates a tree as soon as its first participant joins, so
+	// long as the ID of the tree starts with one of the allowed prefixes
+	CreateByPrefix CreationPolicy = "prefix"
+)
+
+// RegistryConfig governs which trees get created, and for how long they are
+// kept around
+type RegistryConfig struct {
+	// Creation is the creation policy. Unknown policies are treated as
+	// CreateOnJoin
+	Creation CreationPolicy
+
+	// Prefixes are the tree ID prefixes allowed under CreateByPrefix
+	Prefixes []string
+
+	// IdleTTL is how long a tree is kept around while it has no participants.
+	// When zero, trees created by joining them are destroyed as soon as they are
+	// left empty, and trees created via CreateTree are kept until destroyed via
+	// DestroyTree
+	IdleTTL time.Duration
+}
+
+// LifecycleKind is the kind of thing that has happened to a tree
+type LifecycleKind string
+
+const (
+	// TreeCreated is emitted once a tree has been created
+	TreeCreated LifecycleKind = "created"
+
+	// TreeEmptied is emitted once the last participant has left a tree
+	TreeEmptied LifecycleKind = "emptied"
+
+	// TreeDestroyed is emitted once a tree has been destroyed
+	TreeDestroyed LifecycleKind = "destroyed"
+)
+
+// LifecycleEvent tells of a tree having been created, emptied, or destroyed
+type LifecycleEvent struct {
+	TreeId string        `json:"tree"`
+	Kind   LifecycleKind `json:"kind"`
+}
+
+// SetRegistryConfig sets which trees get created from now on, and for how long
+// trees that are left empty from now on are kept around
+func (t *treeManager[K, V]) SetRegistryConfig(config RegistryConfig) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	t.registry = config
+}
+
+// mayCreate determines whether joining the tree with the supplied ID may create
+// it. The caller is expected to already be holding the lock of the registry
+func (t *treeManager[K, V]) mayCreate(treeId string) bool {
+	switch t.registry.Creation {
+	case CreateByAdmin:
+		return false
+	case CreateByPrefix:
+		for _, prefix := range t.registry.Prefixes {
+			if strings.HasPrefix(treeId, prefix) {
+				return true
+			}
+		}
+		return false
+	default:
+		return true
+	}
+}
+
+// CreateTree creates the tree with the supplied ID and config, regardless of
+// the creation policy. Trees created this way are kept around while empty for
+// as long as the idle TTL permits, or until destroyed, if there is no idle TTL
+func (t *treeManager[K, V]) CreateTree(treeId string, config treegraph.Config) error {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+
+	if _, ok := t.trees[treeId]; ok {
+		return ErrTreeExists
+	}
+
+	tree := t.newTree(treeId, config)
+	tree.persistent = true
+	t.trees[treeId] = tree
+
+	return nil
+}
+
+// DestroyTree destroys the tree with the supplied ID, along with everyone in it,
+// and everyone on its waitlist. The change listeners and waitlist listeners of
+// all of them are closed, so as to let them know that they are no longer part
+// of any tree
+func (t *treeManager[K, V]) DestroyTree(treeId string) error {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return ErrTreeNotFound
+	}
+	defer tree.mut.Unlock()
+
+	nodes := tree.tree.AdjacencyList().GetKeys()
+	for nodeId := range nodes.All() {
+		t.cancelSuspension(tree, nodeId)
+	}
+
+	if len(nodes) > 0 {
+		tree.epoch++
+		t.listeners.Emit(treeId, nodes)
+		for nodeId := range nodes.All() {
+			t.nodeListeners.Close(treeNode[K]{treeId, nodeId})
+		}
+	}
+
+	for _, w := range tree.waitlist {
+		t.waitlistListeners.Close(treeNode[K]{treeId, w.nodeId})
+	}
+	tree.waitlist = nil
+
+	t.remove(treeId, tree)
+	return nil
+}
+
+// emptied is to be called once the tree has been left without participants.
+// The tree is either destroyed right away, or once it has been idle for the
+// idle TTL. The caller is expected to already be holding the lock of the tree,
+// but not that of the registry
+func (t *treeManager[K, V]) emptied(treeId string, tree *managedTree[K, V]) {
+	t.lifecycle.Emit(LifecycleEvent{treeId, TreeEmptied})
+
+	t.mut.RLock()
+	ttl := t.registry.IdleTTL
+	t.mut.RUnlock()
+
+	if ttl > 0 {
+		t.startIdleTimer(treeId, tree, ttl)
+	} else if !tree.persistent {
+		t.remove(treeId, tree)
+	}
+}
+
+// startIdleTimer has the tree destroyed once the TTL elapses, unless the tree
+// has gained participants in the meantime. The caller is expected to either be
+// holding the lock of the tree, or to be the only one who knows of the tree
+func (t *treeManager[K, V]) startIdleTimer(
+	treeId string,
+	tree *managedTree[K, V],
+	ttl time.Duration,
+) {
+	t.stopIdleTimer(tree)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		tree.mut.Lock()
+		defer tree.mut.Unlock()
+
+		if tree.removed || tree.idle != timer || !tree.tree.IsEmpty() {
+			return
+		}
+		t.remove(treeId, tree)
+	})
+	tree.idle = timer
+}
+
+// stopIdleTimer keeps the tree from being destroyed for being idle. The caller
+// is expected to already be holding the lock of the tree
+func (t *treeManager[K, V]) stopIdleTimer(tree *managedTree[K, V]) {
+	if tree.idle != nil {
+		tree.idle.Stop()
+		tree.idle = nil
+	}
+}
+
+// RegisterLifecycleListener subscribes to the creation, emptying and
+// destruction of all trees
+func (t *treeManager[K, V]) RegisterLifecycleListener() *listeners.Subscription[LifecycleEvent] {
+	t.mut.RLock()
+	options := listeners.Options[LifecycleEvent]{
+		QueueSize: t.listenerOptions.QueueSize,
+		Policy:    listeners.PolicyDropOldest,
+	}
+	t.mut.RUnlock()
+
+	return t.lifecycle.Subscribe(options)
+}
+
+// UnregisterLifecycleListener ends a subscription made via
+// RegisterLifecycleListener
+func (t *treeManager[K, V]) UnregisterLifecycleListener(
+	listener *listeners.Subscription[LifecycleEvent],
+) {
+	t.lifecycle.Unsubscribe(listener)
+}
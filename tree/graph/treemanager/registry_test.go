@@ -0,0 +1,252 @@
+package treemanager
+
+import (
+	"errors"
+	"testing"
+	"time"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager/listeners"
+)
+
+// receiveLifecycle waits for the next lifecycle event, failing the test should
+// none arrive in time
+func receiveLifecycle(
+	t *testing.T,
+	listener *listeners.Subscription[LifecycleEvent],
+) LifecycleEvent {
+	t.Helper()
+
+	select {
+	case event := <-listener.Events():
+		return event
+	case <-time.After(time.Second):
+		t.Fatal("Expected a lifecycle event")
+		return LifecycleEvent{}
+	}
+}
+
+func TestReadsDoNotCreateTrees(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+
+	manager.GetNeighborOfNode("tree", "a")
+	manager.GetPositionOfNode("tree", "a")
+	manager.GetAdjacencyListAtEpoch("tree")
+	manager.IsRooted("tree")
+	manager.DeleteNode("tree", "a")
+	manager.DisconnectNode("tree", "a", func(int) bool { return true })
+
+	if _, ok := manager.GetTree("tree"); ok {
+		t.Error("Expected reading a tree to not have created it")
+	}
+	if err := manager.MoveNode("tree", "a", "b"); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected moving within a missing tree to fail, but got %v", err)
+	}
+}
+
+func TestUpdateDoesNotCreate(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.MaxParticipants = 1
+	manager := NewTreeManager[string, int](config)
+
+	if _, err := manager.Update("tree", "a", 1); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected updating within a missing tree to fail, but got %v", err)
+	}
+	if _, ok := manager.GetTree("tree"); ok {
+		t.Fatal("Expected updating to not have created the tree")
+	}
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	manager.Upsert("tree", "c", 3)
+	manager.DeleteNode("tree", "c")
+
+	// A node that was deleted stays deleted
+	if _, err := manager.Update("tree", "c", 4); !errors.Is(err, treegraph.ErrNodeNotFound) {
+		t.Errorf("Expected updating a deleted node to fail, but got %v", err)
+	}
+
+	// A waitlisted node stays waitlisted, but has its value replaced
+	change, err := manager.Update("tree", "b", 5)
+	if err != nil || change.Waitlisted != 1 {
+		t.Errorf("Expected b to have stayed first on the waitlist, but got %d, %v", change.Waitlisted, err)
+	}
+
+	_, err = manager.Update("tree", "a", 6)
+	tree := getTree(t, manager, "tree")
+	if found, _ := tree.Find("a"); err != nil {
+		t.Errorf("Expected a to have been updated, but got %v", err)
+	} else if value, _ := found.Get(); value != 6 {
+		t.Errorf("Expected a to have the value 6, but got %d", value)
+	}
+
+	manager.DeleteNode("tree", "a")
+	found, _ := tree.Find("b")
+	if value, ok := found.Get(); !ok || value != 5 {
+		t.Error("Expected b to have been let in with its updated value")
+	}
+	if tree.Len() != 1 {
+		t.Errorf("Expected only b to be in the tree, but got %v", tree.AdjacencyList())
+	}
+}
+
+func TestCreationPolicy(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+
+	manager.SetRegistryConfig(RegistryConfig{Creation: CreateByAdmin})
+	if _, err := manager.Upsert("tree", "a", 1); !errors.Is(err, ErrCreationForbidden) {
+		t.Errorf("Expected joining to not create a tree, but got %v", err)
+	}
+	if _, err := manager.ClaimTreeSource("tree", "a", manager.DefaultConfig()); !errors.Is(err, ErrCreationForbidden) {
+		t.Errorf("Expected claiming the source to not create a tree, but got %v", err)
+	}
+
+	if err := manager.CreateTree("tree", manager.DefaultConfig()); err != nil {
+		t.Fatalf("Expected the tree to have been created, but got %v", err)
+	}
+	if err := manager.CreateTree("tree", manager.DefaultConfig()); !errors.Is(err, ErrTreeExists) {
+		t.Errorf("Expected creating the tree twice to fail, but got %v", err)
+	}
+	if _, err := manager.Upsert("tree", "a", 1); err != nil {
+		t.Errorf("Expected to be able to join a created tree, but got %v", err)
+	}
+
+	// Trees created by the admin outlive their participants
+	manager.DeleteNode("tree", "a")
+	if _, ok := manager.GetTree("tree"); !ok {
+		t.Error("Expected a tree created by the admin to be kept once emptied")
+	}
+
+	manager.SetRegistryConfig(RegistryConfig{
+		Creation: CreateByPrefix,
+		Prefixes: []string{"public-"},
+	})
+	if _, err := manager.Upsert("public-1", "a", 1); err != nil {
+		t.Errorf("Expected joining to create a tree with an allowed prefix, but got %v", err)
+	}
+	if _, err := manager.Upsert("private-1", "a", 1); !errors.Is(err, ErrCreationForbidden) {
+		t.Errorf("Expected joining to not create a tree without an allowed prefix, but got %v", err)
+	}
+
+	// Trees created by joining them go away with their last participant
+	manager.DeleteNode("public-1", "a")
+	if _, ok := manager.GetTree("public-1"); ok {
+		t.Error("Expected a tree created by joining it to be destroyed once emptied")
+	}
+}
+
+func TestIdleTTL(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+	manager.SetRegistryConfig(RegistryConfig{IdleTTL: 50 * time.Millisecond})
+
+	manager.Upsert("tree", "a", 1)
+	manager.DeleteNode("tree", "a")
+	if _, ok := manager.GetTree("tree"); !ok {
+		t.Fatal("Expected an emptied tree to be kept for its idle TTL")
+	}
+
+	// Rejoining within the TTL keeps the tree around
+	time.Sleep(25 * time.Millisecond)
+	manager.Upsert("tree", "a", 1)
+	time.Sleep(50 * time.Millisecond)
+	if _, ok := manager.GetTree("tree"); !ok {
+		t.Fatal("Expected a tree with participants to be kept")
+	}
+
+	manager.DeleteNode("tree", "a")
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := manager.GetTree("tree"); ok {
+		t.Error("Expected the tree to have been destroyed once idle for its TTL")
+	}
+
+	// Trees created by the admin that nobody joins go away too
+	manager.CreateTree("unused", manager.DefaultConfig())
+	time.Sleep(100 * time.Millisecond)
+	if _, ok := manager.GetTree("unused"); ok {
+		t.Error("Expected a tree that was never joined to have been destroyed")
+	}
+}
+
+func TestLifecycleEvents(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+
+	listener := manager.RegisterLifecycleListener()
+	defer manager.UnregisterLifecycleListener(listener)
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	manager.DeleteNode("tree", "a")
+	manager.DeleteNode("tree", "b")
+
+	expected := []LifecycleEvent{
+		{"tree", TreeCreated},
+		{"tree", TreeEmptied},
+		{"tree", TreeDestroyed},
+	}
+	for _, want := range expected {
+		if got := receiveLifecycle(t, listener); got != want {
+			t.Errorf("Expected %v, but got %v", want, got)
+		}
+	}
+}
+
+func TestDestroyTree(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	epoch := manager.Epoch("tree")
+
+	listener := manager.RegisterNodeListener("tree", "b")
+	defer manager.UnregisterNodeListener("tree", "b", listener)
+
+	if err := manager.DestroyTree("tree"); err != nil {
+		t.Fatalf("Expected the tree to have been destroyed, but got %v", err)
+	}
+	if err := manager.DestroyTree("tree"); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected destroying the tree twice to fail, but got %v", err)
+	}
+
+	select {
+	case _, ok := <-listener.Events():
+		if ok {
+			t.Error("Expected the listener of a participant to have been closed")
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected the listener of a participant to have been closed")
+	}
+
+	if manager.Epoch("tree") <= epoch {
+		t.Error("Expected destroying the tree to have bumped its epoch")
+	}
+}
+
+func TestPerTreeConfig(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.Config{MaxDegree: 3, RootDegree: 3})
+
+	audio := treegraph.Config{MaxDegree: 7, RootDegree: 8}
+	if err := manager.CreateTree("audio", audio); err != nil {
+		t.Fatal(err)
+	}
+	if config := getTree(t, manager, "audio").Config(); config.MaxDegree != 7 || config.RootDegree != 8 {
+		t.Errorf("Expected the tree to have been created with its own config, but got %+v", config)
+	}
+
+	video := treegraph.Config{MaxDegree: 2, RootDegree: 2}
+	if _, err := manager.Join("video", "a", 1, video); err != nil {
+		t.Fatal(err)
+	}
+	if config := getTree(t, manager, "video").Config(); config.MaxDegree != 2 {
+		t.Errorf("Expected joining to create the tree with the supplied config, but got %+v", config)
+	}
+
+	// The config of a tree that already exists is left as it is
+	manager.Join("video", "b", 2, audio)
+	if config := getTree(t, manager, "video").Config(); config.MaxDegree != 2 {
+		t.Errorf("Expected the config of an existing tree to be kept, but got %+v", config)
+	}
+
+	manager.Upsert("other", "a", 1)
+	if config := getTree(t, manager, "other").Config(); config.MaxDegree != 3 {
+		t.Errorf("Expected the default config, but got %+v", config)
+	}
+}
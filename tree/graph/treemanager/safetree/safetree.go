@@ -1,6 +1,7 @@
 package safetree
 
 import (
+	"fmt"
 	"sync"
 	"tree/graph/adjacencylist"
 	"tree/graph/maybe"
@@ -9,27 +10,160 @@ import (
 )
 
 type SafeTree[K comparable, V any] struct {
-	mut  *sync.RWMutex
-	tree treegraph.Tree[K, V]
+	mut   *sync.RWMutex
+	tree  treegraph.Tree[K, V]
+	debug bool
 }
 
-func New[K comparable, V any]() SafeTree[K, V] {
+func New[K comparable, V any](config treegraph.Config) SafeTree[K, V] {
 	mut := &sync.RWMutex{}
-	return SafeTree[K, V]{mut, treegraph.Tree[K, V]{}}
+	return SafeTree[K, V]{mut, treegraph.New[K, V](config), false}
+}
+
+// SetDebug turns debug mode on or off. In debug mode, the tree gets validated
+// after every mutation, and a tree that is no longer valid causes a panic
+func (t *SafeTree[K, V]) SetDebug(debug bool) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	t.debug = debug
+}
+
+// check validates the tree, if in debug mode. The caller is expected to already
+// be holding the lock
+func (t *SafeTree[K, V]) check(operation string) {
+	if !t.debug {
+		return
+	}
+	if violations := t.tree.Validate(); len(violations) > 0 {
+		panic(fmt.Sprintf("safetree: %s left the tree invalid: %v", operation, violations))
+	}
+}
+
+func (t SafeTree[K, V]) Validate() adjacencylist.Violations[K] {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Validate()
+}
+
+func (t *SafeTree[K, V]) SetConfig(config treegraph.Config) set.Set[K] {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("SetConfig")
+	return t.tree.SetConfig(config)
+}
+
+// Rebalance moves nodes around, should the tree have grown too lopsided. See
+// treegraph.Tree.Rebalance
+func (t *SafeTree[K, V]) Rebalance(canMove func(key K) bool) ([]K, set.Set[K]) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("Rebalance")
+	return t.tree.Rebalance(canMove)
+}
+
+func (t SafeTree[K, V]) NeedsRebalancing() bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.NeedsRebalancing()
+}
+
+func (t SafeTree[K, V]) Config() treegraph.Config {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Config()
 }
 
 func (t *SafeTree[K, V]) Upsert(key K, value V) set.Set[K] {
 	t.mut.Lock()
 	defer t.mut.Unlock()
+	defer t.check("Upsert")
 	return t.tree.Upsert(key, value)
 }
 
-func (t *SafeTree[K, V]) DeleteByKey(key interface{}) set.Set[K] {
+func (t *SafeTree[K, V]) DeleteByKey(key K) set.Set[K] {
 	t.mut.Lock()
 	defer t.mut.Unlock()
+	defer t.check("DeleteByKey")
 	return t.tree.DeleteByKey(key)
 }
 
+func (t *SafeTree[K, V]) SetSource(key K) set.Set[K] {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("SetSource")
+	return t.tree.SetSource(key)
+}
+
+func (t *SafeTree[K, V]) UnsetSource() {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("UnsetSource")
+	t.tree.UnsetSource()
+}
+
+func (t SafeTree[K, V]) Source() maybe.Maybe[K] {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Source()
+}
+
+func (t SafeTree[K, V]) IsRooted() bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.IsRooted()
+}
+
+func (t *SafeTree[K, V]) Suspend(key K) set.Set[K] {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("Suspend")
+	return t.tree.Suspend(key)
+}
+
+func (t *SafeTree[K, V]) Resume(key K) set.Set[K] {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("Resume")
+	return t.tree.Resume(key)
+}
+
+func (t *SafeTree[K, V]) Move(key, newParent K) (set.Set[K], error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	defer t.check("Move")
+	return t.tree.Move(key, newParent)
+}
+
+func (t *SafeTree[K, V]) Pin(key K) (set.Set[K], error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.tree.Pin(key)
+}
+
+func (t *SafeTree[K, V]) Unpin(key K) (set.Set[K], error) {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.tree.Unpin(key)
+}
+
+func (t SafeTree[K, V]) IsPinned(key K) bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.IsPinned(key)
+}
+
+func (t SafeTree[K, V]) IsSuspended(key K) bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.IsSuspended(key)
+}
+
+func (t SafeTree[K, V]) DeletionStats() treegraph.DeletionStats {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.DeletionStats()
+}
+
 func (t SafeTree[K, V]) Find(key K) (maybe.Maybe[V], bool) {
 	t.mut.RLock()
 	defer t.mut.RUnlock()
@@ -42,6 +176,36 @@ func (t SafeTree[K, V]) GetNeighborOfNode(key K) ([]treegraph.Pair[K, V], bool)
 	return t.tree.GetNeighborsOfNode(key)
 }
 
+func (t SafeTree[K, V]) GetPositionOfNode(key K) (treegraph.Position[K, V], bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.GetPositionOfNode(key)
+}
+
+func (t SafeTree[K, V]) Path(from, to K) ([]treegraph.Pair[K, V], bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Path(from, to)
+}
+
+func (t SafeTree[K, V]) PathToRoot(key K) ([]treegraph.Pair[K, V], bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.PathToRoot(key)
+}
+
+func (t SafeTree[K, V]) IsAncestor(ancestor, key K) bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.IsAncestor(ancestor, key)
+}
+
+func (t SafeTree[K, V]) Flood(origin K, maxHops int, subtree bool) ([]treegraph.Reached[K, V], bool) {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Flood(origin, maxHops, subtree)
+}
+
 func (t SafeTree[K, V]) Has(key K) bool {
 	t.mut.RLock()
 	defer t.mut.RUnlock()
@@ -54,8 +218,30 @@ func (t SafeTree[K, V]) AdjacencyList() adjacencylist.AdjacencyList[K, V] {
 	return t.tree.AdjacencyList()
 }
 
+func (t SafeTree[K, V]) Len() int {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.Len()
+}
+
 func (t SafeTree[K, V]) IsEmpty() bool {
 	t.mut.RLock()
 	defer t.mut.RUnlock()
 	return t.tree.IsEmpty()
 }
+
+// HasVacancy determines whether a new node with the supplied key and value
+// would be placed in the tree. See treegraph.Tree.HasVacancy
+func (t SafeTree[K, V]) HasVacancy(key K, value V) bool {
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.tree.HasVacancy(key, value)
+}
+
+// TakeEvicted gets the nodes that have been evicted from the tree since the
+// last call. See treegraph.Tree.TakeEvicted
+func (t *SafeTree[K, V]) TakeEvicted() []treegraph.Pair[K, V] {
+	t.mut.Lock()
+	defer t.mut.Unlock()
+	return t.tree.TakeEvicted()
+}
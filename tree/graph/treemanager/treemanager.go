@@ -1,84 +1,870 @@
 package treemanager
 
 import (
+	"errors"
 	"sync"
+	"time"
+	"tree/graph/adjacencylist"
+	"tree/graph/set"
 	"tree/graph/treegraph"
 	"tree/graph/treemanager/listeners"
 	"tree/graph/treemanager/safetree"
 )
 
+// ErrTreeNotFound is returned when operating on a tree that does not exist
+var ErrTreeNotFound = errors.New("tree not found")
+
+// treeNode identifies a node in a particular tree
+type treeNode[K comparable] struct {
+	treeId string
+	nodeId K
+}
+
+// Change is the outcome of an operation on a tree: the epoch of the tree as of
+// the operation, along with the position of every node whose neighbors have
+// changed, as of that same epoch. Nodes that are no longer in the tree have no
+// position
+type Change[K comparable, V any] struct {
+	Epoch     uint64
+	Positions map[K]treegraph.Position[K, V]
+
+	// Waitlisted is the position of the upserted node on the waitlist of the
+	// tree, starting at 1, should the tree have been full. It is 0 for nodes that
+	// are in the tree
+	Waitlisted int
+}
+
+// managedTree is a tree, along with everything that the manager keeps track of
+// for it.
+//
+// Every operation on the tree holds the tree's lock from start to finish, so
+// that compound operations are atomic, and so that the changes to the tree are
+// emitted in the order in which they were made
+type managedTree[K comparable, V any] struct {
+	mut  sync.RWMutex
+	tree *safetree.SafeTree[K, V]
+
+	// epoch is bumped every time the tree changes
+	epoch uint64
+
+	// suspensions holds the timers of the suspended nodes, which delete the nodes
+	// once their grace period is up
+	suspensions map[K]*time.Timer
+
+	// waitlist holds the nodes waiting for a seat in the tree, in the order in
+	// which they are to be let in
+	waitlist []waiting[K, V]
+
+	// rebalancer gets closed to stop the tree's background rebalancing, if any
+	rebalancer chan struct{}
+
+	// rebalanced holds when each of the nodes that have been moved by the
+	// rebalancer was moved, for as long as their cooldown lasts. It outlives the
+	// rebalancer, which gets restarted whenever the tree's config changes
+	rebalanced map[K]time.Time
+
+	// idle destroys the tree once it has been without participants for the idle
+	// TTL, if any
+	idle *time.Timer
+
+	// persistent is set for trees created via CreateTree, which are not
+	// destroyed as soon as they are left empty
+	persistent bool
+
+	// removed is set once the tree has been dropped from the registry. A removed
+	// tree is never to be touched again; it is to be looked up anew instead
+	removed bool
+}
+
 type treeManager[K comparable, V any] struct {
-	mut       *sync.RWMutex
-	trees     map[string]*safetree.SafeTree[K, V]
-	listeners listeners.KeyedListeners
+	// mut guards the registry of trees, along with the settings below. It is only
+	// held for as long as it takes to look up, add or remove a tree, and never
+	// while waiting for the lock of a tree
+	mut    *sync.RWMutex
+	trees  map[string]*managedTree[K, V]
+	config treegraph.Config
+	debug  bool
+
+	// registry decides which trees get created, and for how long they are kept
+	registry RegistryConfig
+
+	// listenerOptions is what change listeners get registered with
+	listenerOptions listeners.Options[set.Set[K]]
+
+	// epochs holds the epochs of the trees that have been removed, so that a tree
+	// that gets emptied and then recreated carries on from where it left off
+	epochs map[string]uint64
+
+	listeners listeners.KeyedListeners[string, set.Set[K]]
+
+	// nodeListeners holds the change listeners of individual nodes, which only
+	// get told about changes that affect them, via the epoch of the change
+	nodeListeners listeners.KeyedListeners[treeNode[K], uint64]
+
+	// waitlistListeners holds the listeners of the positions of individual nodes
+	// on the waitlists of their trees
+	waitlistListeners listeners.KeyedListeners[treeNode[K], int]
+
+	// lifecycle holds the listeners of the creation, emptying and destruction of
+	// all trees
+	lifecycle *listeners.Listeners[LifecycleEvent]
 }
 
-func NewTreeManager[K comparable, V any]() treeManager[K, V] {
+// NewTreeManager creates a new tree manager, whose trees will be created with
+// the supplied config, unless they are created with a config of their own
+func NewTreeManager[K comparable, V any](config treegraph.Config) treeManager[K, V] {
 	managerMut := &sync.RWMutex{}
 	return treeManager[K, V]{
-		mut:   managerMut,
-		trees: make(map[string]*safetree.SafeTree[K, V]),
+		mut:       managerMut,
+		trees:     make(map[string]*managedTree[K, V]),
+		listeners: listeners.NewKeyedListeners[string, set.Set[K]](),
+		config:    config,
+		epochs:    make(map[string]uint64),
+
+		nodeListeners:     listeners.NewKeyedListeners[treeNode[K], uint64](),
+		waitlistListeners: listeners.NewKeyedListeners[treeNode[K], int](),
+		lifecycle:         listeners.NewListeners[LifecycleEvent](),
+
+		listenerOptions: listeners.Options[set.Set[K]]{
+			QueueSize: listeners.DefaultQueueSize,
+			Policy:    listeners.PolicyCoalesce,
+			Coalesce: func(older, newer set.Set[K]) set.Set[K] {
+				return older.Union(newer)
+			},
+		},
 	}
 }
 
-func (t *treeManager[K, V]) GetTree(id string) *safetree.SafeTree[K, V] {
+// GetTree gets the tree with the supplied ID, if there is one. The tree is not
+// to be changed directly, as such changes would go unnoticed by the manager
+func (t *treeManager[K, V]) GetTree(id string) (*safetree.SafeTree[K, V], bool) {
+	tree, ok := t.rlock(id)
+	if !ok {
+		return nil, false
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree, true
+}
+
+// SetDebug turns debug mode on or off for all trees, present and future. See
+// safetree.SafeTree.SetDebug
+func (t *treeManager[K, V]) SetDebug(debug bool) {
 	t.mut.Lock()
 	defer t.mut.Unlock()
 
-	tree, ok := t.trees[id]
-	if !ok {
-		newTree := safetree.New[K, V]()
-		t.trees[id] = &newTree
-		tree = &newTree
+	t.debug = debug
+	for _, tree := range t.trees {
+		tree.tree.SetDebug(debug)
+	}
+}
+
+// DefaultConfig gets the config that trees are created with, unless they are
+// created with a config of their own
+func (t *treeManager[K, V]) DefaultConfig() treegraph.Config {
+	return t.config
+}
+
+// acquire gets the tree with the supplied ID, creating it with the supplied
+// config if it does not already exist, and the creation policy permits. The
+// tree is returned locked, and it is up to the caller to unlock it
+func (t *treeManager[K, V]) acquire(treeId string, config treegraph.Config) (*managedTree[K, V], error) {
+	for {
+		t.mut.RLock()
+		tree, ok := t.trees[treeId]
+		t.mut.RUnlock()
+
+		if !ok {
+			t.mut.Lock()
+			tree, ok = t.trees[treeId]
+			if !ok {
+				if !t.mayCreate(treeId) {
+					t.mut.Unlock()
+					return nil, ErrCreationForbidden
+				}
+				tree = t.newTree(treeId, config)
+				t.trees[treeId] = tree
+			}
+			t.mut.Unlock()
+		}
+
+		tree.mut.Lock()
+		if !tree.removed {
+			return tree, nil
+		}
+
+		// The tree was removed while waiting for its lock
+		tree.mut.Unlock()
+	}
+}
+
+// lock gets the tree with the supplied ID, if there is one. The tree is
+// returned locked, and it is up to the caller to unlock it
+func (t *treeManager[K, V]) lock(treeId string) (*managedTree[K, V], bool) {
+	for {
+		t.mut.RLock()
+		tree, ok := t.trees[treeId]
+		t.mut.RUnlock()
+		if !ok {
+			return nil, false
+		}
+
+		tree.mut.Lock()
+		if !tree.removed {
+			return tree, true
+		}
+		tree.mut.Unlock()
+	}
+}
+
+// rlock gets the tree with the supplied ID, if there is one. The tree is
+// returned read-locked, and it is up to the caller to unlock it
+func (t *treeManager[K, V]) rlock(treeId string) (*managedTree[K, V], bool) {
+	for {
+		t.mut.RLock()
+		tree, ok := t.trees[treeId]
+		t.mut.RUnlock()
+		if !ok {
+			return nil, false
+		}
+
+		tree.mut.RLock()
+		if !tree.removed {
+			return tree, true
+		}
+		tree.mut.RUnlock()
+	}
+}
+
+// newTree creates the tree with the supplied ID and config, picking up the
+// epoch where a previous tree of the same ID left off. The caller is expected
+// to already be holding the lock of the registry
+func (t *treeManager[K, V]) newTree(treeId string, config treegraph.Config) *managedTree[K, V] {
+	safeTree := safetree.New[K, V](config)
+	safeTree.SetDebug(t.debug)
+
+	tree := &managedTree[K, V]{
+		tree:        &safeTree,
+		epoch:       t.epochs[treeId],
+		suspensions: make(map[K]*time.Timer),
+		rebalanced:  make(map[K]time.Time),
+	}
+	delete(t.epochs, treeId)
+
+	// Nobody else knows of the tree yet, but the timers that get started do
+	tree.mut.Lock()
+	defer tree.mut.Unlock()
+
+	t.startRebalancer(treeId, tree)
+	if t.registry.IdleTTL > 0 {
+		t.startIdleTimer(treeId, tree, t.registry.IdleTTL)
 	}
 
+	t.lifecycle.Emit(LifecycleEvent{treeId, TreeCreated})
 	return tree
 }
 
-func (t *treeManager[K, V]) Upsert(treeId string, nodeId K, p V) {
+// remove drops the tree from the registry, thereby destroying it. The caller is
+// expected to already be holding the lock of the tree, but not that of the
+// registry
+func (t *treeManager[K, V]) remove(treeId string, tree *managedTree[K, V]) {
+	tree.removed = true
+	t.stopRebalancer(tree)
+	t.stopIdleTimer(tree)
+	for nodeId, timer := range tree.suspensions {
+		timer.Stop()
+		delete(tree.suspensions, nodeId)
+	}
+
 	t.mut.Lock()
 	defer t.mut.Unlock()
-	tree := t.GetTree(treeId)
+	t.epochs[treeId] = tree.epoch
+	delete(t.trees, treeId)
+
+	t.lifecycle.Emit(LifecycleEvent{treeId, TreeDestroyed})
+}
+
+// SetTreeConfig replaces the config of the tree with the supplied ID, moving
+// nodes around as needed
+func (t *treeManager[K, V]) SetTreeConfig(treeId string, config treegraph.Config) error {
+	return t.UpdateTreeConfig(treeId, func(treegraph.Config) treegraph.Config {
+		return config
+	})
+}
+
+// UpdateTreeConfig replaces the config of the tree with the supplied ID with
+// whatever the update makes of its current config, moving nodes around as
+// needed. Nothing else may change the config in between
+func (t *treeManager[K, V]) UpdateTreeConfig(
+	treeId string,
+	update func(treegraph.Config) treegraph.Config,
+) error {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return ErrTreeNotFound
+	}
+	defer tree.mut.Unlock()
+
+	changedNodes := tree.tree.SetConfig(update(tree.tree.Config()))
+	changedNodes = changedNodes.Union(t.promote(treeId, tree))
+	t.emit(treeId, tree, changedNodes)
+
+	t.stopRebalancer(tree)
+	t.startRebalancer(treeId, tree)
+	return nil
+}
+
+// Upsert upserts the node into the tree with the supplied ID, creating the
+// tree with the default config should the creation policy permit. See Join
+func (t *treeManager[K, V]) Upsert(treeId string, nodeId K, p V) (Change[K, V], error) {
+	return t.Join(treeId, nodeId, p, t.config)
+}
+
+// Join upserts the node into the tree with the supplied ID, creating the tree
+// with the supplied config should the creation policy permit. The config is
+// ignored for trees that already exist. A node that was suspended is resumed,
+// right where it was left. Should the tree be full, or should no node in the
+// tree have room for another child, the node is put on the tree's waitlist
+// instead, and let in once a seat frees up.
+//
+// Returns the new position of every node affected by the upsert, as of the
+// epoch of the upsert, or the position of the node on the waitlist
+func (t *treeManager[K, V]) Join(treeId string, nodeId K, p V, config treegraph.Config) (Change[K, V], error) {
+	tree, err := t.acquire(treeId, config)
+	if err != nil {
+		return Change[K, V]{}, err
+	}
+	defer tree.mut.Unlock()
+
+	if !tree.hasSeat(nodeId, p) {
+		return Change[K, V]{
+			Epoch:      tree.epoch,
+			Positions:  map[K]treegraph.Position[K, V]{},
+			Waitlisted: t.joinWaitlist(treeId, tree, nodeId, p),
+		}, nil
+	}
+
+	t.cancelSuspension(tree, nodeId)
+	t.stopIdleTimer(tree)
+
+	// Resuming the node, or having it declare more capacity, may very well have
+	// made room for those that are waiting
+	changedNodes := tree.tree.Resume(nodeId).Union(tree.tree.Upsert(nodeId, p))
+	changedNodes = changedNodes.Union(t.promote(treeId, tree))
+	return t.emit(treeId, tree, changedNodes), nil
+}
+
+// Update replaces the value of a node that is already in the tree with the
+// supplied ID, or that is on the tree's waitlist. Unlike Join, it never creates
+// the tree, lets the node into the tree, or resumes the node, so that a
+// connection that is no longer current is unable to put back a node that has
+// since been deleted. Returns ErrTreeNotFound should there be no such tree, and
+// treegraph.ErrNodeNotFound should the node be neither in the tree nor on its
+// waitlist
+func (t *treeManager[K, V]) Update(treeId string, nodeId K, p V) (Change[K, V], error) {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return Change[K, V]{}, ErrTreeNotFound
+	}
+	defer tree.mut.Unlock()
+
+	if position := tree.waitlistPosition(nodeId); position > 0 {
+		tree.waitlist[position-1].value = p
+		return Change[K, V]{
+			Epoch:      tree.epoch,
+			Positions:  map[K]treegraph.Position[K, V]{},
+			Waitlisted: position,
+		}, nil
+	}
+
+	if !tree.tree.Has(nodeId) {
+		return Change[K, V]{}, treegraph.ErrNodeNotFound
+	}
 
-	changedNodes := tree.Upsert(nodeId, p)
-	t.listeners.EmitEvent(treeId, changedNodes)
+	// Having the node declare more capacity may very well have made room for
+	// those that are waiting
+	changedNodes := tree.tree.Upsert(nodeId, p).Union(t.promote(treeId, tree))
+	return t.emit(treeId, tree, changedNodes), nil
 }
 
+// GetNeighborOfNode gets the neighbors of the node. A tree that does not exist
+// has no nodes
 func (t *treeManager[K, V]) GetNeighborOfNode(treeId string, nodeId K) ([]treegraph.Pair[K, V], bool) {
-	t.mut.Lock()
-	defer t.mut.Unlock()
-	tree := t.GetTree(treeId)
+	neighbors, _, ok := t.GetNeighborsAtEpoch(treeId, nodeId)
+	return neighbors, ok
+}
+
+// ClaimTreeSource pins the node as the source of the tree with the supplied ID,
+// unless the tree already has a different source, creating the tree with the
+// supplied config should the creation policy permit. The source is released
+// once its node is deleted from the tree, but not while the node is merely
+// suspended. Returns whether the node is now the source of the tree
+func (t *treeManager[K, V]) ClaimTreeSource(treeId string, nodeId K, config treegraph.Config) (bool, error) {
+	tree, err := t.acquire(treeId, config)
+	if err != nil {
+		return false, err
+	}
+	defer tree.mut.Unlock()
+
+	if source, ok := tree.tree.Source().Get(); ok {
+		return source == nodeId, nil
+	}
 
-	neighbor, ok := tree.GetNeighborOfNode(nodeId)
+	changedNodes := tree.tree.SetSource(nodeId)
+	t.emit(treeId, tree, changedNodes)
+	return true, nil
+}
+
+// IsRooted determines whether the tree with the supplied ID has a source. A
+// tree that does not exist has none
+func (t *treeManager[K, V]) IsRooted(treeId string) bool {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return false
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree.IsRooted()
+}
+
+// GetPositionOfNode gets the position of the node. A tree that does not exist
+// has no nodes
+func (t *treeManager[K, V]) GetPositionOfNode(treeId string, nodeId K) (treegraph.Position[K, V], bool) {
+	position, _, ok := t.GetPositionAtEpoch(treeId, nodeId)
+	return position, ok
+}
+
+// GetPath gets the nodes along the path through the tree from one node to
+// another, both of them included. A tree that does not exist has no nodes
+func (t *treeManager[K, V]) GetPath(treeId string, from, to K) ([]treegraph.Pair[K, V], bool) {
+	tree, ok := t.rlock(treeId)
 	if !ok {
 		return nil, false
 	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree.Path(from, to)
+}
+
+// GetPathToRoot gets the nodes along the path through the tree from the node
+// up to the root, both of them included. A tree that does not exist has no
+// nodes
+func (t *treeManager[K, V]) GetPathToRoot(treeId string, nodeId K) ([]treegraph.Pair[K, V], bool) {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return nil, false
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree.PathToRoot(nodeId)
+}
+
+// IsAncestor determines whether one node is upstream of another
+func (t *treeManager[K, V]) IsAncestor(treeId string, ancestor, nodeId K) bool {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return false
+	}
+	defer tree.mut.RUnlock()
 
-	return neighbor, true
+	return tree.tree.IsAncestor(ancestor, nodeId)
 }
 
-func (t *treeManager[K, V]) DeleteNode(treeId, nodeId string) {
+// Flood gets every node that a flood from the supplied node reaches. See
+// treegraph.Tree.Flood. A tree that does not exist has no nodes
+func (t *treeManager[K, V]) Flood(
+	treeId string,
+	origin K,
+	maxHops int,
+	subtree bool,
+) ([]treegraph.Reached[K, V], bool) {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return nil, false
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree.Flood(origin, maxHops, subtree)
+}
+
+// DeleteNode deletes the node from the tree. See emptied for what becomes of a
+// tree that is left empty.
+//
+// Returns the new position of every node affected by the deletion, as of the
+// epoch of the deletion
+func (t *treeManager[K, V]) DeleteNode(treeId string, nodeId K) Change[K, V] {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return Change[K, V]{Epoch: t.Epoch(treeId)}
+	}
+	defer tree.mut.Unlock()
+
+	return t.deleteNode(treeId, tree, nodeId)
+}
+
+// deleteNode deletes the node from the tree, or takes it off the waitlist, and
+// lets whoever is next on the waitlist into the tree. The caller is expected to
+// already be holding the lock of the tree
+func (t *treeManager[K, V]) deleteNode(
+	treeId string,
+	tree *managedTree[K, V],
+	nodeId K,
+) Change[K, V] {
+	if t.leaveWaitlist(treeId, tree, nodeId) {
+		return Change[K, V]{Epoch: tree.epoch, Positions: map[K]treegraph.Position[K, V]{}}
+	}
+
+	t.cancelSuspension(tree, nodeId)
+
+	changedNodes := tree.tree.DeleteByKey(nodeId)
+	if len(changedNodes) > 0 {
+		changedNodes = changedNodes.Union(t.promote(treeId, tree))
+	}
+	change := t.emit(treeId, tree, changedNodes)
+
+	if len(changedNodes) > 0 && tree.tree.IsEmpty() {
+		t.emptied(treeId, tree)
+	}
+
+	return change
+}
+
+// DisconnectNode is to be called once the node has lost its connection.
+//
+// A waitlisted node is taken off the waitlist right away. Otherwise, if the
+// tree has a grace period, the node is suspended, and only deleted once
+// the grace period elapses without the node having been upserted again in the
+// meantime. Otherwise, the node is deleted right away.
+//
+// Since the node may very well have reconnected already, by the time the old
+// connection is found to be gone, the node is only touched if `owns` holds for
+// the node's current value
+func (t *treeManager[K, V]) DisconnectNode(treeId string, nodeId K, owns func(V) bool) {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return
+	}
+	defer tree.mut.Unlock()
+
+	if position := tree.waitlistPosition(nodeId); position > 0 {
+		if owns(tree.waitlist[position-1].value) {
+			t.leaveWaitlist(treeId, tree, nodeId)
+		}
+		return
+	}
+
+	maybeValue, ok := tree.tree.Find(nodeId)
+	if !ok {
+		return
+	}
+	if value, ok := maybeValue.Get(); !ok || !owns(value) {
+		return
+	}
+
+	grace := tree.tree.Config().GracePeriod
+	if grace <= 0 {
+		t.deleteNode(treeId, tree, nodeId)
+		return
+	}
+
+	t.cancelSuspension(tree, nodeId)
+
+	var timer *time.Timer
+	timer = time.AfterFunc(grace, func() {
+		tree, ok := t.lock(treeId)
+		if !ok {
+			return
+		}
+		defer tree.mut.Unlock()
+
+		// The node may have been resumed, or suspended anew, in the meantime
+		if tree.suspensions[nodeId] != timer {
+			return
+		}
+		t.deleteNode(treeId, tree, nodeId)
+	})
+	tree.suspensions[nodeId] = timer
+
+	changedNodes := tree.tree.Suspend(nodeId)
+	t.emit(treeId, tree, changedNodes)
+}
+
+// MoveNode makes the node a child of the new parent, along with its subtree.
+// See treegraph.Tree.Move
+func (t *treeManager[K, V]) MoveNode(treeId string, nodeId, newParent K) error {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return ErrTreeNotFound
+	}
+	defer tree.mut.Unlock()
+
+	changedNodes, err := tree.tree.Move(nodeId, newParent)
+	if err != nil {
+		return err
+	}
+
+	t.emit(treeId, tree, changedNodes.Union(t.promote(treeId, tree)))
+	return nil
+}
+
+// PinNode pins or unpins the node, so as to keep the tree from moving it. See
+// treegraph.Tree.Pin. Like any other change to the tree, pinning or unpinning a
+// node bumps the epoch of the tree, and is emitted to the tree's listeners
+func (t *treeManager[K, V]) PinNode(treeId string, nodeId K, pinned bool) error {
+	tree, ok := t.lock(treeId)
+	if !ok {
+		return ErrTreeNotFound
+	}
+	defer tree.mut.Unlock()
+
+	pin := tree.tree.Unpin
+	if pinned {
+		pin = tree.tree.Pin
+	}
+
+	changedNodes, err := pin(nodeId)
+	if err != nil {
+		return err
+	}
+
+	t.emit(treeId, tree, changedNodes)
+	return nil
+}
+
+// emit bumps the epoch of the tree, and lets the tree's listeners know which of
+// the nodes have changed, should any of them have changed. Of the listeners of
+// individual nodes, only those of the changed nodes are told.
+//
+// Whoever was evicted from the tree by the change is put on the waitlist. See
+// waitlistEvicted.
+//
+// Returns the position of every changed node that is still in the tree. The
+// caller is expected to already be holding the lock of the tree
+func (t *treeManager[K, V]) emit(
+	treeId string,
+	tree *managedTree[K, V],
+	changedNodes set.Set[K],
+) Change[K, V] {
+	t.waitlistEvicted(treeId, tree)
+
+	change := Change[K, V]{
+		Epoch:     tree.epoch,
+		Positions: map[K]treegraph.Position[K, V]{},
+	}
+	if len(changedNodes) <= 0 {
+		return change
+	}
+
+	tree.epoch++
+	change.Epoch = tree.epoch
+
+	for nodeId := range changedNodes.All() {
+		if position, ok := tree.tree.GetPositionOfNode(nodeId); ok {
+			change.Positions[nodeId] = position
+		}
+	}
+
+	t.listeners.Emit(treeId, changedNodes)
+	for nodeId := range changedNodes.All() {
+		t.nodeListeners.Emit(treeNode[K]{treeId, nodeId}, tree.epoch)
+	}
+
+	return change
+}
+
+// Epoch gets the epoch of the tree with the supplied ID. The epoch starts at 0,
+// and is bumped every time the tree changes
+func (t *treeManager[K, V]) Epoch(treeId string) uint64 {
+	if tree, ok := t.rlock(treeId); ok {
+		defer tree.mut.RUnlock()
+		return tree.epoch
+	}
+
+	t.mut.RLock()
+	defer t.mut.RUnlock()
+	return t.epochs[treeId]
+}
+
+// GetNeighborsAtEpoch gets the neighbors of the node, along with the epoch of
+// the tree that the neighbors are as of
+func (t *treeManager[K, V]) GetNeighborsAtEpoch(
+	treeId string,
+	nodeId K,
+) ([]treegraph.Pair[K, V], uint64, bool) {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return nil, t.Epoch(treeId), false
+	}
+	defer tree.mut.RUnlock()
+
+	neighbors, ok := tree.tree.GetNeighborOfNode(nodeId)
+	return neighbors, tree.epoch, ok
+}
+
+// GetPositionAtEpoch gets the position of the node, along with the epoch of the
+// tree that the position is as of
+func (t *treeManager[K, V]) GetPositionAtEpoch(
+	treeId string,
+	nodeId K,
+) (treegraph.Position[K, V], uint64, bool) {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return treegraph.Position[K, V]{}, t.Epoch(treeId), false
+	}
+	defer tree.mut.RUnlock()
+
+	position, ok := tree.tree.GetPositionOfNode(nodeId)
+	return position, tree.epoch, ok
+}
+
+// GetAdjacencyListAtEpoch gets the adjacency list of the tree, along with the
+// epoch that the adjacency list is as of. A tree that does not exist is empty
+func (t *treeManager[K, V]) GetAdjacencyListAtEpoch(
+	treeId string,
+) (adjacencylist.AdjacencyList[K, V], uint64) {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return adjacencylist.AdjacencyList[K, V]{}, t.Epoch(treeId)
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.tree.AdjacencyList(), tree.epoch
+}
+
+// cancelSuspension stops the node from being deleted at the end of its grace
+// period. The caller is expected to already be holding the lock of the tree
+func (t *treeManager[K, V]) cancelSuspension(tree *managedTree[K, V], nodeId K) {
+	if timer, ok := tree.suspensions[nodeId]; ok {
+		timer.Stop()
+		delete(tree.suspensions, nodeId)
+	}
+}
+
+// startRebalancer starts rebalancing the tree in the background, every interval
+// set by the tree's config, if any. The caller is expected to either be holding
+// the lock of the tree, or to be the only one who knows of the tree
+func (t *treeManager[K, V]) startRebalancer(treeId string, tree *managedTree[K, V]) {
+	config := tree.tree.Config().Rebalance
+	if config.Interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	tree.rebalancer = stop
+	go t.rebalance(treeId, tree, config, stop)
+}
+
+// stopRebalancer stops the tree's background rebalancing, if any. The caller is
+// expected to already be holding the lock of the tree
+func (t *treeManager[K, V]) stopRebalancer(tree *managedTree[K, V]) {
+	if tree.rebalancer != nil {
+		close(tree.rebalancer)
+		tree.rebalancer = nil
+	}
+}
+
+// rebalance periodically rebalances the tree, until told to stop. Nodes that
+// have been moved are left alone for the cooldown set by the config, so that
+// no node gets bounced around
+func (t *treeManager[K, V]) rebalance(
+	treeId string,
+	tree *managedTree[K, V],
+	config treegraph.RebalanceConfig,
+	stop chan struct{},
+) {
+	ticker := time.NewTicker(config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case now := <-ticker.C:
+			tree.mut.Lock()
+
+			// The rebalancer may have been stopped while waiting for the lock
+			if tree.removed || tree.rebalancer != stop {
+				tree.mut.Unlock()
+				return
+			}
+
+			for key, at := range tree.rebalanced {
+				if now.Sub(at) >= config.Cooldown {
+					delete(tree.rebalanced, key)
+				}
+			}
+
+			keys, changedNodes := tree.tree.Rebalance(func(key K) bool {
+				_, ok := tree.rebalanced[key]
+				return !ok
+			})
+			for _, key := range keys {
+				tree.rebalanced[key] = now
+			}
+			t.emit(treeId, tree, changedNodes)
+
+			tree.mut.Unlock()
+		}
+	}
+}
+
+// SetListenerPolicy sets how many change events may be waiting to be delivered
+// to a change listener, and what happens to change listeners that fall further
+// behind than that. This only applies to change listeners registered from now
+// on.
+//
+// Under listeners.PolicyCoalesce, which is the default, the sets of changed
+// nodes get merged
+func (t *treeManager[K, V]) SetListenerPolicy(queueSize int, policy listeners.Policy) {
 	t.mut.Lock()
 	defer t.mut.Unlock()
-	tree := t.GetTree(treeId)
 
-	changedNodes := tree.DeleteByKey(nodeId)
-	if tree.IsEmpty() {
-		delete(t.trees, treeId)
+	t.listenerOptions.QueueSize = queueSize
+	t.listenerOptions.Policy = policy
+}
+
+// RegisterChangeListener subscribes to the changes made to the tree with the
+// supplied ID. Every event holds the keys of the nodes that have changed
+func (t *treeManager[K, V]) RegisterChangeListener(
+	treeId string,
+) *listeners.Subscription[set.Set[K]] {
+	t.mut.RLock()
+	options := t.listenerOptions
+	t.mut.RUnlock()
+
+	return t.listeners.Subscribe(treeId, options)
+}
+
+// RegisterNodeListener subscribes to the changes made to the tree with the
+// supplied ID that affect the node with the supplied ID. Every event holds the
+// epoch of the tree as of the change. Events that the node falls behind on are
+// coalesced into the latest one
+func (t *treeManager[K, V]) RegisterNodeListener(
+	treeId string,
+	nodeId K,
+) *listeners.Subscription[uint64] {
+	t.mut.RLock()
+	options := listeners.Options[uint64]{
+		QueueSize: t.listenerOptions.QueueSize,
+		Policy:    t.listenerOptions.Policy,
 	}
+	t.mut.RUnlock()
 
-	t.listeners.EmitEvent(treeId, changedNodes)
+	return t.nodeListeners.Subscribe(treeNode[K]{treeId, nodeId}, options)
 }
 
-func (t *treeManager[K, V]) RegisterChangeListener(
-	treeId interface{},
-) <-chan interface{} {
-	return t.listeners.RegisterListener(treeId)
+// UnregisterNodeListener ends a subscription made via RegisterNodeListener
+func (t *treeManager[K, V]) UnregisterNodeListener(
+	treeId string,
+	nodeId K,
+	listener *listeners.Subscription[uint64],
+) {
+	t.nodeListeners.Unsubscribe(treeNode[K]{treeId, nodeId}, listener)
 }
 
 func (t *treeManager[K, V]) UnregisterChangeListener(
-	treeId interface{},
-	listener <-chan interface{},
+	treeId string,
+	listener *listeners.Subscription[set.Set[K]],
 ) {
-	t.listeners.UnregisterListener(treeId, listener)
+	t.listeners.Unsubscribe(treeId, listener)
 }
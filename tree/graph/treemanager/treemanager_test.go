@@ -0,0 +1,489 @@
+package treemanager
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager/safetree"
+)
+
+// getTree gets the tree with the supplied ID, failing the test should there be
+// no such tree
+func getTree(
+	t *testing.T,
+	manager treeManager[string, int],
+	treeId string,
+) *safetree.SafeTree[string, int] {
+	t.Helper()
+
+	tree, ok := manager.GetTree(treeId)
+	if !ok {
+		t.Fatalf("Expected tree %s to exist", treeId)
+	}
+	return tree
+}
+
+func TestGracePeriod(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.GracePeriod = 50 * time.Millisecond
+	manager := NewTreeManager[string, int](config)
+
+	owns := func(value int) func(int) bool {
+		return func(v int) bool { return v == value }
+	}
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	manager.Upsert("tree", "c", 3)
+
+	before, _ := manager.GetPositionOfNode("tree", "c")
+
+	// Disconnecting with a stale connection should be a no-op
+	manager.DisconnectNode("tree", "c", owns(42))
+	if getTree(t, manager, "tree").IsSuspended("c") {
+		t.Fatal("Expected c to not have been suspended by a stale connection")
+	}
+
+	manager.DisconnectNode("tree", "c", owns(3))
+	if !getTree(t, manager, "tree").IsSuspended("c") {
+		t.Fatal("Expected c to have been suspended")
+	}
+
+	// Reconnecting within the grace period puts the node right back
+	manager.Upsert("tree", "c", 4)
+	time.Sleep(100 * time.Millisecond)
+
+	after, ok := manager.GetPositionOfNode("tree", "c")
+	if !ok {
+		t.Fatal("Expected c to still be in the tree")
+	}
+	beforeParent, _ := before.Parent.Get()
+	afterParent, _ := after.Parent.Get()
+	if beforeParent.Key != afterParent.Key || getTree(t, manager, "tree").IsSuspended("c") {
+		t.Error("Expected c to have been given its position back")
+	}
+
+	// Not reconnecting within the grace period deletes the node
+	manager.DisconnectNode("tree", "c", owns(4))
+	time.Sleep(100 * time.Millisecond)
+
+	if getTree(t, manager, "tree").Has("c") {
+		t.Error("Expected c to have been deleted after the grace period")
+	}
+}
+
+func TestSourceReleased(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.GracePeriod = 50 * time.Millisecond
+	manager := NewTreeManager[string, int](config)
+
+	owns := func(int) bool { return true }
+	claim := func(nodeId string) bool {
+		claimed, _ := manager.ClaimTreeSource("tree", nodeId, config)
+		return claimed
+	}
+
+	claim("a")
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	if claim("b") {
+		t.Fatal("Expected b to not be able to claim a source that is taken")
+	}
+
+	// The source is only released once its grace period is over
+	manager.DisconnectNode("tree", "a", owns)
+	if claim("b") {
+		t.Error("Expected the suspended source to keep its claim")
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if manager.IsRooted("tree") {
+		t.Error("Expected the tree to no longer be rooted once the source was deleted")
+	}
+	if !claim("b") {
+		t.Error("Expected b to be able to claim the source once a was gone")
+	}
+}
+
+func TestBackgroundRebalancing(t *testing.T) {
+	config := treegraph.Config{MaxDegree: 3, RootDegree: 2}
+	config.Rebalance.Interval = 10 * time.Millisecond
+	config.Rebalance.Moves = 100
+	manager := NewTreeManager[string, int](config)
+
+	// Grow the tree as a chain, and then lift the limit, so as to leave it
+	// lopsided
+	manager.CreateTree("tree", treegraph.Config{MaxDegree: 2, RootDegree: 1})
+	for i := 0; i < 15; i++ {
+		manager.Upsert("tree", fmt.Sprint(i), i)
+	}
+
+	listener := manager.RegisterChangeListener("tree")
+	defer manager.UnregisterChangeListener("tree", listener)
+
+	manager.SetTreeConfig("tree", config)
+
+	deadline := time.After(time.Second)
+	for getTree(t, manager, "tree").NeedsRebalancing() {
+		select {
+		case <-listener.Events():
+		case <-deadline:
+			t.Fatal("Expected the tree to have been rebalanced in the background")
+		}
+	}
+}
+
+func TestEpochs(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.DefaultConfig())
+
+	if epoch := manager.Epoch("tree"); epoch != 0 {
+		t.Errorf("Expected a new tree to be at epoch 0, but got %d", epoch)
+	}
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	_, epoch, _ := manager.GetNeighborsAtEpoch("tree", "a")
+	if epoch != 2 {
+		t.Errorf("Expected the tree to be at epoch 2, but got %d", epoch)
+	}
+
+	// Deleting the last node deletes the tree, but not its epoch
+	manager.DeleteNode("tree", "a")
+	manager.DeleteNode("tree", "b")
+	manager.Upsert("tree", "c", 3)
+	if _, epoch := manager.GetAdjacencyListAtEpoch("tree"); epoch != 5 {
+		t.Errorf("Expected the tree to be at epoch 5, but got %d", epoch)
+	}
+
+	// Failing to move a node changes nothing
+	manager.MoveNode("tree", "c", "c")
+	if epoch := manager.Epoch("tree"); epoch != 5 {
+		t.Errorf("Expected the tree to still be at epoch 5, but got %d", epoch)
+	}
+
+	// Pinning a node is a change like any other, unless it is pinned already
+	manager.PinNode("tree", "c", true)
+	manager.PinNode("tree", "c", true)
+	if epoch := manager.Epoch("tree"); epoch != 6 {
+		t.Errorf("Expected pinning to have bumped the epoch once, to 6, but got %d", epoch)
+	}
+	manager.PinNode("tree", "c", false)
+	if epoch := manager.Epoch("tree"); epoch != 7 {
+		t.Errorf("Expected unpinning to have bumped the epoch to 7, but got %d", epoch)
+	}
+}
+
+func TestNodeListeners(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.Config{MaxDegree: 4, RootDegree: 3})
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+	manager.Upsert("tree", "c", 3)
+
+	listeners := map[string]<-chan uint64{}
+	for _, key := range []string{"a", "b", "c", "d"} {
+		listener := manager.RegisterNodeListener("tree", key)
+		defer manager.UnregisterNodeListener("tree", key, listener)
+		listeners[key] = listener.Events()
+	}
+
+	// d joins as a child of the root, which leaves its siblings untouched
+	manager.Upsert("tree", "d", 4)
+	epoch := manager.Epoch("tree")
+
+	for _, key := range []string{"a", "d"} {
+		select {
+		case got := <-listeners[key]:
+			if got != epoch {
+				t.Errorf("Expected %s to be told about epoch %d, but got %d", key, epoch, got)
+			}
+		case <-time.After(time.Second):
+			t.Errorf("Expected %s to be told about the change", key)
+		}
+	}
+
+	for _, key := range []string{"b", "c"} {
+		select {
+		case got := <-listeners[key]:
+			t.Errorf("Expected %s to not be told about the change, but got epoch %d", key, got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// A metadata-only upsert of b tells its neighbor a about the new value, and
+	// leaves everyone else be
+	manager.Upsert("tree", "b", 20)
+	epoch = manager.Epoch("tree")
+
+	select {
+	case got := <-listeners["a"]:
+		if got != epoch {
+			t.Errorf("Expected a to be told about epoch %d, but got %d", epoch, got)
+		}
+		neighbors, _ := manager.GetNeighborOfNode("tree", "a")
+		for _, n := range neighbors {
+			if n.Key == "b" && n.Value != 20 {
+				t.Errorf("Expected a to see the new value of b, but got %d", n.Value)
+			}
+		}
+	case <-time.After(time.Second):
+		t.Error("Expected a to be told about the new value of b")
+	}
+
+	for _, key := range []string{"c", "d"} {
+		select {
+		case got := <-listeners[key]:
+			t.Errorf("Expected %s to not be told about the new value of b, but got epoch %d", key, got)
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+
+	// Listeners of other trees hear nothing at all
+	other := manager.RegisterNodeListener("other", "a")
+	defer manager.UnregisterNodeListener("other", "a", other)
+	manager.Upsert("tree", "e", 5)
+	select {
+	case got := <-other.Events():
+		t.Errorf("Expected a listener of another tree to not be told, but got epoch %d", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestConcurrentStress has hundreds of participants join, broadcast to their
+// neighbors, and leave, all at once, across several trees, while an admin moves
+// participants around, and the trees rebalance in the background. It is meant
+// to be run under the race detector. Debug mode has the trees validated after
+// every change
+func TestConcurrentStress(t *testing.T) {
+	config := treegraph.Config{MaxDegree: 4, RootDegree: 3, GracePeriod: 5 * time.Millisecond}
+	config.Rebalance.Interval = 2 * time.Millisecond
+	config.Rebalance.Moves = 4
+	manager := NewTreeManager[string, int](config)
+	manager.SetDebug(true)
+
+	treeIds := []string{"a", "b", "c"}
+	participants := 100
+	rounds := 5
+	if testing.Short() {
+		participants = 20
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+
+	// Watchers make sure that every tree they see is valid, and that epochs
+	// never go backwards
+	var watchers sync.WaitGroup
+	defer watchers.Wait()
+	for _, treeId := range treeIds {
+		listener := manager.RegisterChangeListener(treeId)
+		defer manager.UnregisterChangeListener(treeId, listener)
+
+		watchers.Add(1)
+		go func() {
+			defer watchers.Done()
+
+			last := uint64(0)
+			for range listener.Events() {
+				list, epoch := manager.GetAdjacencyListAtEpoch(treeId)
+				if epoch < last {
+					t.Errorf("Epoch of tree %s went back from %d to %d", treeId, last, epoch)
+				}
+				last = epoch
+				if violations := list.Validate(config.MaxDegree); len(violations) > 0 {
+					t.Errorf("Tree %s is invalid at epoch %d: %v", treeId, epoch, violations)
+				}
+			}
+		}()
+	}
+
+	// An admin moves random participants under other random participants
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		r := rand.New(rand.NewSource(1))
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			treeId := treeIds[r.Intn(len(treeIds))]
+			manager.MoveNode(
+				treeId,
+				fmt.Sprint(r.Intn(participants)),
+				fmt.Sprint(r.Intn(participants)),
+			)
+			time.Sleep(100 * time.Microsecond)
+		}
+	}()
+
+	var participantsDone sync.WaitGroup
+	for i := 0; i < participants*len(treeIds); i++ {
+		treeId := treeIds[i%len(treeIds)]
+		nodeId := fmt.Sprint(i / len(treeIds))
+
+		participantsDone.Add(1)
+		go func() {
+			defer participantsDone.Done()
+			r := rand.New(rand.NewSource(int64(i)))
+
+			listener := manager.RegisterNodeListener(treeId, nodeId)
+			defer manager.UnregisterNodeListener(treeId, nodeId, listener)
+			go func() {
+				for range listener.Events() {
+				}
+			}()
+
+			for round := 0; round < rounds; round++ {
+				value := i*rounds + round
+				change, err := manager.Upsert(treeId, nodeId, value)
+				if err != nil {
+					t.Errorf("Expected %s to be able to join tree %s, but got %v", nodeId, treeId, err)
+					return
+				}
+
+				// Should every node with room to spare be suspended, there is no
+				// room for anyone else until they are either resumed or deleted
+				if change.Waitlisted > 0 {
+					manager.DeleteNode(treeId, nodeId)
+					continue
+				}
+
+				// The upsert and the positions of everyone it affected are to be
+				// seen as one
+				position, ok := change.Positions[nodeId]
+				if !ok {
+					t.Errorf("Expected %s to have a position after joining tree %s", nodeId, treeId)
+					return
+				}
+				if parent, ok := position.Parent.Get(); ok {
+					parentPosition, ok := change.Positions[parent.Key]
+					if !ok {
+						t.Errorf("Expected the parent of %s to have been affected by it joining", nodeId)
+					} else if !slices.ContainsFunc(parentPosition.Children, func(child treegraph.Pair[string, int]) bool {
+						return child.Key == nodeId
+					}) {
+						t.Errorf("Expected %s to be among the children of its parent %s", nodeId, parent.Key)
+					}
+				}
+
+				// Broadcast to the neighbors, and have them look up their own
+				// neighbors in turn
+				neighbors, _, _ := manager.GetNeighborsAtEpoch(treeId, nodeId)
+				for _, neighbor := range neighbors {
+					manager.GetPositionAtEpoch(treeId, neighbor.Key)
+				}
+
+				if r.Intn(2) == 0 {
+					manager.DisconnectNode(treeId, nodeId, func(v int) bool { return v == value })
+				} else {
+					manager.DeleteNode(treeId, nodeId)
+				}
+				time.Sleep(time.Duration(r.Intn(200)) * time.Microsecond)
+			}
+
+			manager.DeleteNode(treeId, nodeId)
+		}()
+	}
+
+	participantsDone.Wait()
+	close(stop)
+	wg.Wait()
+
+	manager.mut.RLock()
+	remaining := len(manager.trees)
+	manager.mut.RUnlock()
+	if remaining > 0 {
+		t.Errorf("Expected every tree to have been dropped once emptied, but %d remain", remaining)
+	}
+
+	for _, treeId := range treeIds {
+		if manager.Epoch(treeId) == 0 {
+			t.Errorf("Expected tree %s to have kept its epoch after being dropped", treeId)
+		}
+	}
+}
+
+func TestUpdateTreeConfig(t *testing.T) {
+	manager := NewTreeManager[string, int](treegraph.Config{MaxDegree: 3, RootDegree: 3})
+
+	if err := manager.UpdateTreeConfig("tree", func(c treegraph.Config) treegraph.Config {
+		return c
+	}); !errors.Is(err, ErrTreeNotFound) {
+		t.Errorf("Expected updating a missing tree to fail, but got %v", err)
+	}
+
+	manager.CreateTree("tree", manager.DefaultConfig())
+	for i := 0; i < 4; i++ {
+		manager.Upsert("tree", fmt.Sprint(i), i)
+	}
+
+	err := manager.UpdateTreeConfig("tree", func(c treegraph.Config) treegraph.Config {
+		c.RootDegree = 1
+		return c
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tree := getTree(t, manager, "tree")
+	if config := tree.Config(); config.RootDegree != 1 || config.MaxDegree != 3 {
+		t.Errorf("Expected only the root degree to have changed, but got %+v", config)
+	}
+	if neighbors, _ := manager.GetNeighborOfNode("tree", "0"); len(neighbors) != 1 {
+		t.Errorf("Expected the root to have been left with a single child, but got %v", neighbors)
+	}
+}
+
+func TestRebalanceCooldownOutlivesRestarts(t *testing.T) {
+	config := treegraph.Config{MaxDegree: 3, RootDegree: 2}
+	config.Rebalance.Interval = 5 * time.Millisecond
+	config.Rebalance.Moves = 1
+	config.Rebalance.Cooldown = time.Hour
+	manager := NewTreeManager[string, int](config)
+
+	manager.CreateTree("tree", treegraph.Config{MaxDegree: 2, RootDegree: 1})
+	for i := 0; i < 15; i++ {
+		manager.Upsert("tree", fmt.Sprint(i), i)
+	}
+
+	listener := manager.RegisterChangeListener("tree")
+	defer manager.UnregisterChangeListener("tree", listener)
+	manager.SetTreeConfig("tree", config)
+
+	select {
+	case <-listener.Events():
+	case <-time.After(time.Second):
+		t.Fatal("Expected the tree to have been rebalanced in the background")
+	}
+
+	rebalanced := func() map[string]time.Time {
+		tree, _ := manager.lock("tree")
+		defer tree.mut.Unlock()
+		return maps.Clone(tree.rebalanced)
+	}
+	before := rebalanced()
+	if len(before) <= 0 {
+		t.Fatal("Expected a node to have been moved by the rebalancer")
+	}
+
+	// Changing the config restarts the rebalancer, which is not to forget who is
+	// still cooling down
+	manager.UpdateTreeConfig("tree", func(c treegraph.Config) treegraph.Config {
+		c.Rebalance.Moves = 2
+		return c
+	})
+	after := rebalanced()
+	for key, at := range before {
+		if !after[key].Equal(at) {
+			t.Errorf("Expected %s to still be cooling down since %v, but got %v", key, at, after[key])
+		}
+	}
+}
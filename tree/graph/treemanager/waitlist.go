@@ -0,0 +1,193 @@
+package treemanager
+
+import (
+	"slices"
+	"tree/graph/set"
+	"tree/graph/treemanager/listeners"
+)
+
+// waiting is a node on the waitlist of a tree, along with the value that it is
+// to be upserted with once it is let into the tree
+type waiting[K comparable, V any] struct {
+	nodeId K
+	value  V
+}
+
+// waitlistPosition gets the position of the node on the waitlist of the tree,
+// starting at 1, or 0 should the node not be waitlisted. The caller is expected
+// to already be holding the lock of the tree
+func (tree *managedTree[K, V]) waitlistPosition(nodeId K) int {
+	for i, w := range tree.waitlist {
+		if w.nodeId == nodeId {
+			return i + 1
+		}
+	}
+	return 0
+}
+
+// hasSeat determines whether the node may be upserted into the tree right away.
+// Nodes already in the tree, and the source, always may. Any other node only
+// may while the tree is below its maximum number of participants, the tree has
+// room for the node, and nobody else is waiting. The caller is expected to
+// already be holding the lock of the tree
+func (tree *managedTree[K, V]) hasSeat(nodeId K, value V) bool {
+	if tree.tree.Has(nodeId) {
+		return true
+	}
+	if source, ok := tree.tree.Source().Get(); ok && source == nodeId {
+		return true
+	}
+	return len(tree.waitlist) <= 0 && tree.hasVacancy(nodeId, value)
+}
+
+// hasVacancy determines whether the tree is able to take on the node, both as
+// far as its maximum number of participants, and as far as the room that its
+// nodes have to spare, are concerned. See treegraph.Tree.HasVacancy. The caller
+// is expected to already be holding the lock of the tree
+func (tree *managedTree[K, V]) hasVacancy(nodeId K, value V) bool {
+	max := tree.tree.Config().MaxParticipants
+	if max > 0 && tree.tree.Len() >= max {
+		return false
+	}
+	return tree.tree.HasVacancy(nodeId, value)
+}
+
+// joinWaitlist puts the node at the end of the waitlist of the tree. A node that
+// is already waitlisted keeps its position, and merely has its value replaced.
+// Returns the position of the node. The caller is expected to already be
+// holding the lock of the tree
+func (t *treeManager[K, V]) joinWaitlist(
+	treeId string,
+	tree *managedTree[K, V],
+	nodeId K,
+	value V,
+) int {
+	if position := tree.waitlistPosition(nodeId); position > 0 {
+		tree.waitlist[position-1].value = value
+		return position
+	}
+
+	tree.waitlist = append(tree.waitlist, waiting[K, V]{nodeId, value})
+	position := len(tree.waitlist)
+	t.waitlistListeners.Emit(treeNode[K]{treeId, nodeId}, position)
+	return position
+}
+
+// leaveWaitlist takes the node off the waitlist of the tree, moving everyone
+// behind it up. Returns whether the node was waitlisted. The caller is expected
+// to already be holding the lock of the tree
+func (t *treeManager[K, V]) leaveWaitlist(
+	treeId string,
+	tree *managedTree[K, V],
+	nodeId K,
+) bool {
+	position := tree.waitlistPosition(nodeId)
+	if position <= 0 {
+		return false
+	}
+
+	tree.waitlist = slices.Delete(tree.waitlist, position-1, position)
+	t.emitWaitlist(treeId, tree, position-1)
+	return true
+}
+
+// waitlistEvicted puts the nodes that were evicted from the tree, for want of
+// room, at the front of the waitlist of the tree, since they were in the tree
+// before anyone else that is waiting. Evicted nodes that were suspended are let
+// go of instead, as they would have been once their grace period was over. The
+// caller is expected to already be holding the lock of the tree
+func (t *treeManager[K, V]) waitlistEvicted(treeId string, tree *managedTree[K, V]) {
+	evicted := []waiting[K, V]{}
+	for _, pair := range tree.tree.TakeEvicted() {
+		t.cancelSuspension(tree, pair.Key)
+		if !pair.Suspended {
+			evicted = append(evicted, waiting[K, V]{pair.Key, pair.Value})
+		}
+	}
+
+	if len(evicted) > 0 {
+		tree.waitlist = append(evicted, tree.waitlist...)
+		t.emitWaitlist(treeId, tree, 0)
+	}
+}
+
+// promote lets nodes off the front of the waitlist of the tree into the tree,
+// for as long as there are seats to spare, after putting whoever was evicted
+// from the tree on the waitlist. Returns the keys of all nodes whose neighbors
+// have changed. The caller is expected to already be holding the lock of the
+// tree
+func (t *treeManager[K, V]) promote(treeId string, tree *managedTree[K, V]) set.Set[K] {
+	t.waitlistEvicted(treeId, tree)
+
+	changedNodes := set.Set[K]{}
+
+	promoted := 0
+	for promoted < len(tree.waitlist) {
+		w := tree.waitlist[promoted]
+		if !tree.hasVacancy(w.nodeId, w.value) {
+			break
+		}
+		promoted++
+
+		t.stopIdleTimer(tree)
+		changedNodes = changedNodes.Union(tree.tree.Upsert(w.nodeId, w.value))
+		t.waitlistListeners.Emit(treeNode[K]{treeId, w.nodeId}, 0)
+	}
+
+	if promoted > 0 {
+		tree.waitlist = slices.Delete(tree.waitlist, 0, promoted)
+		t.emitWaitlist(treeId, tree, 0)
+	}
+
+	return changedNodes
+}
+
+// emitWaitlist lets every node on the waitlist of the tree, from the supplied
+// index on, know of its position. The caller is expected to already be holding
+// the lock of the tree
+func (t *treeManager[K, V]) emitWaitlist(treeId string, tree *managedTree[K, V], from int) {
+	for i := from; i < len(tree.waitlist); i++ {
+		t.waitlistListeners.Emit(treeNode[K]{treeId, tree.waitlist[i].nodeId}, i+1)
+	}
+}
+
+// WaitlistPosition gets the position of the node on the waitlist of the tree
+// with the supplied ID, starting at 1, or 0 should the node not be waitlisted
+func (t *treeManager[K, V]) WaitlistPosition(treeId string, nodeId K) int {
+	tree, ok := t.rlock(treeId)
+	if !ok {
+		return 0
+	}
+	defer tree.mut.RUnlock()
+
+	return tree.waitlistPosition(nodeId)
+}
+
+// RegisterWaitlistListener subscribes to the position of the node with the
+// supplied ID on the waitlist of the tree with the supplied ID. Every event
+// holds the position of the node, starting at 1. An event of 0 means that the
+// node has been let into the tree. Should the node fall behind, only its latest
+// position is kept
+func (t *treeManager[K, V]) RegisterWaitlistListener(
+	treeId string,
+	nodeId K,
+) *listeners.Subscription[int] {
+	t.mut.RLock()
+	options := listeners.Options[int]{
+		QueueSize: t.listenerOptions.QueueSize,
+		Policy:    listeners.PolicyCoalesce,
+	}
+	t.mut.RUnlock()
+
+	return t.waitlistListeners.Subscribe(treeNode[K]{treeId, nodeId}, options)
+}
+
+// UnregisterWaitlistListener ends a subscription made via
+// RegisterWaitlistListener
+func (t *treeManager[K, V]) UnregisterWaitlistListener(
+	treeId string,
+	nodeId K,
+	listener *listeners.Subscription[int],
+) {
+	t.waitlistListeners.Unsubscribe(treeNode[K]{treeId, nodeId}, listener)
+}
@@ -0,0 +1,194 @@
+package treemanager
+
+import (
+	"testing"
+	"time"
+	"tree/graph/maybe"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager/listeners"
+)
+
+// receivePosition waits for the next waitlist position, failing the test should
+// none arrive in time
+func receivePosition(t *testing.T, listener *listeners.Subscription[int]) int {
+	t.Helper()
+
+	select {
+	case position := <-listener.Events():
+		return position
+	case <-time.After(time.Second):
+		t.Fatal("Expected a waitlist position")
+		return 0
+	}
+}
+
+func TestWaitlist(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.MaxParticipants = 2
+	manager := NewTreeManager[string, int](config)
+
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 2)
+
+	listener := manager.RegisterWaitlistListener("tree", "d")
+	defer manager.UnregisterWaitlistListener("tree", "d", listener)
+
+	change, _ := manager.Upsert("tree", "c", 3)
+	if change.Waitlisted != 1 {
+		t.Errorf("Expected c to be first on the waitlist, but got %d", change.Waitlisted)
+	}
+	change, _ = manager.Upsert("tree", "d", 4)
+	if change.Waitlisted != 2 {
+		t.Errorf("Expected d to be second on the waitlist, but got %d", change.Waitlisted)
+	}
+	if got := receivePosition(t, listener); got != 2 {
+		t.Errorf("Expected d to be told it is second, but got %d", got)
+	}
+
+	tree := getTree(t, manager, "tree")
+	if tree.Has("c") || tree.Has("d") {
+		t.Fatal("Expected waitlisted nodes to not be in the tree")
+	}
+
+	// Updating a waitlisted node keeps it where it is
+	change, _ = manager.Upsert("tree", "c", 5)
+	if change.Waitlisted != 1 {
+		t.Errorf("Expected c to have kept its place, but got %d", change.Waitlisted)
+	}
+
+	// A seat freeing up lets in whoever is first
+	manager.DeleteNode("tree", "a")
+	if !tree.Has("c") {
+		t.Error("Expected c to have been let into the tree")
+	}
+	if value, _ := tree.Find("c"); value != maybe.Something(5) {
+		t.Error("Expected c to have been let in with its latest value")
+	}
+	if got := receivePosition(t, listener); got != 1 {
+		t.Errorf("Expected d to be told it is now first, but got %d", got)
+	}
+
+	// Disconnecting takes a node off the waitlist
+	manager.DisconnectNode("tree", "d", func(v int) bool { return v == 4 })
+	if manager.WaitlistPosition("tree", "d") != 0 {
+		t.Error("Expected d to have left the waitlist")
+	}
+
+	// Raising the limit lets everyone in
+	manager.Upsert("tree", "e", 6)
+	manager.Upsert("tree", "f", 7)
+	config.MaxParticipants = 4
+	manager.SetTreeConfig("tree", config)
+	if !tree.Has("e") || !tree.Has("f") {
+		t.Error("Expected everyone to have been let in once the limit was raised")
+	}
+}
+
+func TestWaitlistSource(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.MaxParticipants = 1
+	manager := NewTreeManager[string, int](config)
+
+	manager.Upsert("tree", "a", 1)
+	manager.ClaimTreeSource("tree", "source", config)
+
+	change, _ := manager.Upsert("tree", "source", 2)
+	if change.Waitlisted != 0 || !getTree(t, manager, "tree").Has("source") {
+		t.Error("Expected the source to have been let in, regardless of the limit")
+	}
+}
+
+// relay is a value that declares how many children the node is able to take on
+type relay int
+
+func (r relay) DeclaredCapacity() treegraph.Capacity {
+	return treegraph.Capacity{Children: maybe.Something(int(r))}
+}
+
+func TestWaitlistWithoutRoom(t *testing.T) {
+	manager := NewTreeManager[string, relay](treegraph.DefaultConfig())
+
+	// With a being the source, nobody may take over as the root either
+	manager.ClaimTreeSource("tree", "a", treegraph.DefaultConfig())
+	manager.Upsert("tree", "a", 1)
+	manager.Upsert("tree", "b", 0)
+	tree, _ := manager.GetTree("tree")
+
+	// Everyone in the tree is full, regardless of what the config permits
+	change, _ := manager.Upsert("tree", "c", 0)
+	if change.Waitlisted != 1 || tree.Has("c") {
+		t.Errorf("Expected c to have been waitlisted, but got %d", change.Waitlisted)
+	}
+
+	// Having b declare room lets c in, underneath b
+	manager.Upsert("tree", "b", 1)
+	position, ok := tree.GetPositionOfNode("c")
+	if !ok {
+		t.Fatal("Expected c to have been let in once b had room")
+	}
+	if parent, _ := position.Parent.Get(); parent.Key != "b" {
+		t.Errorf("Expected c to have been placed under b, but got %v", parent.Key)
+	}
+
+	// Those who have no room left in the tree go to the front of the waitlist
+	manager.Upsert("tree", "d", 0)
+	manager.Upsert("tree", "e", 0)
+	manager.Upsert("tree", "a", 0)
+	if tree.Len() != 1 {
+		t.Errorf("Expected only a to be left in the tree, but got %v", tree.AdjacencyList())
+	}
+	for i, nodeId := range []string{"b", "c", "d", "e"} {
+		if got := manager.WaitlistPosition("tree", nodeId); got != i+1 {
+			t.Errorf("Expected %s to be at position %d, but got %d", nodeId, i+1, got)
+		}
+	}
+	if violations := tree.Validate(); len(violations) > 0 {
+		t.Errorf("Expected no violations, but got %v", violations)
+	}
+}
+
+func TestWaitlistSuspendedRoot(t *testing.T) {
+	config := treegraph.DefaultConfig()
+	config.GracePeriod = time.Minute
+	manager := NewTreeManager[string, int](config)
+
+	manager.ClaimTreeSource("tree", "a", config)
+	manager.Upsert("tree", "a", 1)
+	manager.DisconnectNode("tree", "a", func(v int) bool { return true })
+
+	change, _ := manager.Upsert("tree", "b", 2)
+	if change.Waitlisted != 1 {
+		t.Errorf("Expected b to wait for the suspended root, but got %d", change.Waitlisted)
+	}
+
+	manager.Upsert("tree", "a", 1)
+	if !getTree(t, manager, "tree").Has("b") {
+		t.Error("Expected b to have been let in once the root was resumed")
+	}
+}
+
+func TestWaitlistZeroCapacityRoot(t *testing.T) {
+	manager := NewTreeManager[string, relay](treegraph.DefaultConfig())
+
+	// The first to join declared no room, which leaves no room for anyone else
+	// without room either
+	manager.Upsert("tree", "viewer", 0)
+	tree, _ := manager.GetTree("tree")
+	if tree.HasVacancy("other", 0) {
+		t.Error("Expected there to be no room for other")
+	}
+
+	// Someone with room takes over as the root, rather than waiting
+	change, _ := manager.Upsert("tree", "publisher", 2)
+	if change.Waitlisted != 0 || !tree.Has("publisher") {
+		t.Fatalf("Expected publisher to have been let in, but got %v", tree.AdjacencyList())
+	}
+	if position, _ := tree.GetPositionOfNode("publisher"); position.Depth != 0 {
+		t.Errorf("Expected publisher to have become the root, but it is at depth %d", position.Depth)
+	}
+
+	change, _ = manager.Upsert("tree", "other", 0)
+	if change.Waitlisted != 0 || !tree.Has("other") {
+		t.Errorf("Expected other to have been let in under publisher, but got %v", tree.AdjacencyList())
+	}
+}
@@ -1,14 +1,20 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"strconv"
 	"sync"
 	"time"
 
+	"tree/access"
+	"tree/graph/adjacencylist"
+	"tree/graph/treegraph"
 	"tree/graph/treemanager"
 	"tree/ws"
 
@@ -28,20 +34,38 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
-var trees = treemanager.NewTreeManager[string, participant]()
+var trees = treemanager.NewTreeManager[string, participant](GetTreeConfig())
 
-// TODO: Gotta find a better name for this.
-//
-// Perhaps move this to another file
-type participant struct {
-	writer ws.Writer
-	meta   json.RawMessage
+// policies decides who may join which tree
+var policies = access.NewPolicies()
+
+// rootedNeighbors is what a NEIGHBORS message holds in a tree that has a
+// source, where every participant is told which of its neighbors is upstream
+type rootedNeighbors struct {
+	Parent   *treegraph.Pair[string, json.RawMessage]  `json:"parent"`
+	Children []treegraph.Pair[string, json.RawMessage] `json:"children"`
+	Depth    int                                       `json:"depth"`
 }
 
-var _ json.Marshaler = &participant{}
+// newRootedNeighbors describes the position of a participant, with the
+// metadata of its neighbors stripped of the tree's private fields
+func newRootedNeighbors(
+	position treegraph.Position[string, participant],
+	policy access.Policy,
+) rootedNeighbors {
+	var parent *treegraph.Pair[string, json.RawMessage]
+	if p, ok := position.Parent.Get(); ok {
+		parent = &viewPairs([]treegraph.Pair[string, participant]{p}, policy, access.WatchPublic)[0]
+	}
+	return rootedNeighbors{parent, viewPairs(position.Children, policy, access.WatchPublic), position.Depth}
+}
 
-func (p *participant) MarshalJSON() ([]byte, error) {
-	return p.meta, nil
+// epochMessage is a message that describes the topology of a tree as of the
+// epoch that it carries, so that clients are able to drop outdated messages
+type epochMessage struct {
+	Type  string `json:"type"`
+	Epoch uint64 `json:"epoch"`
+	Data  any    `json:"data"`
 }
 
 type TypeData struct {
@@ -49,6 +73,45 @@ type TypeData struct {
 	Data json.RawMessage `json:"data"`
 }
 
+// writeTreeNotFound lets the client know that the tree it is joining does not
+// exist, and may not be created by joining it
+func writeTreeNotFound(writer messageWriter, treeID string) {
+	writer.WriteJSON(map[string]any{
+		"type": "CLIENT_ERROR",
+		"data": map[string]any{
+			"type": "TREE_NOT_FOUND",
+			"data": map[string]any{
+				"message": fmt.Sprintf("Tree %s not found", treeID),
+			},
+		},
+	})
+}
+
+// writeAccessError lets the client know why it may not join the tree
+func writeAccessError(writer messageWriter, treeID string, err error) {
+	errorType := "ACCESS_DENIED"
+	switch {
+	case errors.Is(err, access.ErrInviteRequired):
+		errorType = "INVITE_REQUIRED"
+	case errors.Is(err, access.ErrInvalidInvite):
+		errorType = "INVALID_INVITE"
+	case errors.Is(err, access.ErrInviteExpired):
+		errorType = "INVITE_EXPIRED"
+	case errors.Is(err, access.ErrInviteMismatch):
+		errorType = "INVITE_MISMATCH"
+	}
+
+	writer.WriteJSON(map[string]any{
+		"type": "CLIENT_ERROR",
+		"data": map[string]any{
+			"type": errorType,
+			"data": map[string]any{
+				"message": fmt.Sprintf("Not permitted to join tree %s: %s", treeID, err.Error()),
+			},
+		},
+	})
+}
+
 func handleTree(w http.ResponseWriter, r *http.Request) {
 	// This is where we handle the act of adding a node to a tree
 
@@ -72,13 +135,13 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 		done <- true
 	}
 
-	write := func(handler func() error) {
-		err := c.SetWriteDeadline(time.Now().Add(ws.WriteWait))
-		if err != nil {
-			close()
-		}
-		err = handler()
-		if err != nil {
+	// Everything written to the client goes through the one writer, including
+	// whatever other participants relay to it, and what is aggregated for it
+	// upstream, so that no two goroutines write to the connection at once
+	writer := ws.NewWriter(c)
+
+	write := func(v any) {
+		if err := writer.WriteJSON(v); err != nil {
 			close()
 		}
 	}
@@ -89,18 +152,14 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 	if !ok {
 		// This should have technically not been possible at all. Thus closing the
 		// connection, while also notifying the client that something went wrong.
-		write(func() error {
-			return c.WriteJSON(
-				map[string]any{
-					"type": "SERVER_ERROR",
-					"data": map[string]any{
-						"type": "UNKNOWN_ERROR",
-						"data": map[string]string{
-							"title": "An internal server error",
-						},
-					},
+		write(map[string]any{
+			"type": "SERVER_ERROR",
+			"data": map[string]any{
+				"type": "UNKNOWN_ERROR",
+				"data": map[string]string{
+					"title": "An internal server error",
 				},
-			)
+			},
 		})
 		return
 	}
@@ -114,15 +173,78 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writer := ws.NewWriter(c)
+	// Only now that the client has proven that it owns its key can its invite be
+	// checked against it
+	role, err := policies.Admit(treeID, clientID, r.URL.Query().Get("invite"), time.Now())
+	if err != nil {
+		writeAccessError(writer, treeID, err)
+		return
+	}
 
-	p := participant{writer, json.RawMessage([]byte("{}"))}
+	// Clients that connect with the `envelope` query parameter have whatever is
+	// relayed to them wrapped in an envelope, which tells them who it is from
+	s := session{treeID, clientID, writer, role, r.URL.Query().Has("envelope")}
 
-	trees.Upsert(treeID, clientID, p)
-	defer trees.DeleteNode(treeID, clientID)
+	// Whoever's joining creates the tree, should it not exist yet, and may
+	// supply the settings that it gets created with. The settings of a tree that
+	// already exists are left as they are
+	settings, err := treeSettingsFromQuery(r.URL.Query())
+	if err != nil {
+		s.writeError("CLIENT_ERROR", "INVALID_TREE_SETTINGS", map[string]any{
+			"message": err.Error(),
+		})
+		return
+	}
+	config := settings.apply(trees.DefaultConfig())
+	p := newParticipant(s, json.RawMessage([]byte("{}")))
+
+	// A client that connects with the `source` query parameter is asking to be
+	// pinned as the root of the tree, which is only granted to publishers, and
+	// only if no other client has already claimed it
+	if r.URL.Query().Has("source") {
+		if !role.Can(access.ActionSource) {
+			s.writeForbidden(access.ActionSource, nil)
+			return
+		}
+		claimed, err := trees.ClaimTreeSource(treeID, clientID, config)
+		if err != nil {
+			writeTreeNotFound(writer, treeID)
+			return
+		}
+		if !claimed {
+			writer.WriteJSON(map[string]any{
+				"type": "CLIENT_ERROR",
+				"data": map[string]any{
+					"type": "SOURCE_TAKEN",
+					"data": map[string]any{
+						"message": fmt.Sprintf("Tree %s already has a source", treeID),
+					},
+				},
+			})
+			return
+		}
+	}
 
-	listener := trees.RegisterChangeListener(treeID)
-	defer trees.UnregisterChangeListener(treeID, listener)
+	// Listen for changes before joining the tree, so as not to miss out on the
+	// participant's own arrival. Only the changes that affect this participant's
+	// neighbors are heard of
+	listener := trees.RegisterNodeListener(treeID, clientID)
+	defer trees.UnregisterNodeListener(treeID, clientID, listener)
+
+	// Should the tree be full, the participant is put on its waitlist, and kept
+	// up to date with its position, until a seat frees up
+	waitlist := trees.RegisterWaitlistListener(treeID, clientID)
+	defer trees.UnregisterWaitlistListener(treeID, clientID, waitlist)
+
+	// Should the participant be reconnecting within the tree's grace period, this
+	// puts it right back where it was
+	if _, err := trees.Join(treeID, clientID, p, config); err != nil {
+		writeTreeNotFound(writer, treeID)
+		return
+	}
+	defer trees.DisconnectNode(treeID, clientID, func(p participant) bool {
+		return p.writer == writer
+	})
 
 	var wg sync.WaitGroup
 	wg.Add(3)
@@ -139,125 +261,59 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 				return
 			}
 
-			switch td.Type {
-			case "SET_META":
-				trees.Upsert(treeID, clientID, participant{writer, td.Data})
-			case "BROADCAST":
-				neighbors, ok := trees.GetNeighborOfNode(treeID, clientID)
+			s.handleMessage(td)
+		}
+	}()
 
-				if ok {
-					for _, n := range neighbors {
-						if n.Value.writer.WriteJSON(td.Data) != nil {
-							writer.WriteJSON(map[string]any{
-								"type": "SERVER_ERROR",
-								"data": map[string]any{
-									"type": "UNABLE_TO_SEND_MESSAGE",
-									"data": map[string]any{
-										"message": fmt.Sprintf("In broadcast, error sending message to participant with client ID of %s. Could be that the participant is no longer there", n.Key),
-										"meta": map[string]any{
-											"error":            err.Error(),
-											"to":               n.Key,
-											"original_message": td.Data,
-										},
-									},
-								},
-							})
-						}
-					}
-				}
-			case "SEND":
-				type message struct {
-					To   string          `json:"to"`
-					Data json.RawMessage `json:"data"`
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case position, ok := <-waitlist.Events():
+				if !ok {
+					c.Close()
+					return
 				}
 
-				var m message
-				err := json.Unmarshal(td.Data, &m)
-				if err != nil {
-					writer.WriteJSON(map[string]any{
-						"type": "CLIENT_ERROR",
+				// Once let into the tree, the participant hears of its neighbors
+				// like anyone else
+				if position > 0 {
+					write(map[string]any{
+						"type": "WAITLISTED",
 						"data": map[string]any{
-							"type": "MALFORMED_MESSAGE",
-							"data": map[string]any{
-								"title":   "Message intended for participant was malformed",
-								"message": fmt.Sprintf("Error parsing the message that was intended for participant %s", m.To),
-								"meta": map[string]any{
-									"error": err.Error(),
-									"to":    m.To,
-								},
-							},
+							"position": position,
 						},
 					})
-					continue
+				}
+			case _, ok := <-listener.Events():
+				if !ok {
+					// The participant fell too far behind on changes to the tree, or
+					// the tree was destroyed, and was disconnected from them. Closing
+					// the connection has the reading goroutine wind everything else
+					// down
+					c.Close()
+					return
 				}
 
-				neighbors, ok := trees.GetNeighborOfNode(treeID, clientID)
-
-				if ok {
-					sent := false
-					for _, n := range neighbors {
-						if n.Key == m.To {
-							sent = true
-							if n.Value.writer.WriteJSON(m.Data) != nil {
-								writer.WriteJSON(map[string]any{
-									"type": "SERVER_ERROR",
-									"data": map[string]any{
-										"type": "UNABLE_TO_SEND_MESSAGE",
-										"data": map[string]any{
-											"message": fmt.Sprintf("Error sending message to participant with client ID of %s. Could be that the participant is no longer there", n.Key),
-											"meta": map[string]any{
-												"error":            err.Error(),
-												"to":               m.To,
-												"original_message": td.Data,
-											},
-										},
-									},
-								})
-							}
-							continue
-						}
-					}
-
-					if !sent {
-						writer.WriteJSON(map[string]any{
-							"type": "CLIENT_ERROR",
-							"data": map[string]any{
-								"type": "PARTICIPANT_NOT_FOUND",
-								"data": map[string]any{
-									"message": fmt.Sprintf("Participant with ID %s not found", m.To),
-									"meta": map[string]any{
-										"to":               m.To,
-										"original_message": td.Data,
-									},
-								},
-							},
+				if trees.IsRooted(treeID) {
+					position, epoch, ok := trees.GetPositionAtEpoch(treeID, clientID)
+					if ok {
+						write(epochMessage{
+							Type:  "NEIGHBORS",
+							Epoch: epoch,
+							Data:  newRootedNeighbors(position, policies.Get(treeID)),
 						})
 					}
+					continue
 				}
-			}
-		}
-	}()
 
-	go func() {
-		defer wg.Done()
-
-		type typeAny struct {
-			Type string `json:"type"`
-			Data any    `json:"data"`
-		}
-
-		for {
-			select {
-			case <-listener:
-				neighbors, ok := trees.GetNeighborOfNode(treeID, clientID)
+				neighbors, epoch, ok := trees.GetNeighborsAtEpoch(treeID, clientID)
 				if ok {
-					write(func() error {
-						return c.WriteJSON(
-							typeAny{
-								Type: "NEIGHBORS",
-								Data: neighbors,
-							},
-						)
+					write(epochMessage{
+						Type:  "NEIGHBORS",
+						Epoch: epoch,
+						Data:  viewPairs(neighbors, policies.Get(treeID), access.WatchPublic),
 					})
 				}
 			case <-done:
@@ -276,8 +332,7 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 		for {
 			select {
 			case <-ticker.C:
-				c.SetWriteDeadline(time.Now().Add(ws.WriteWait))
-				if err := c.WriteMessage(websocket.PingMessage, nil); err != nil {
+				if err := writer.WriteMessage(websocket.PingMessage, nil); err != nil {
 					return
 				}
 			case <-done:
@@ -289,6 +344,72 @@ func handleTree(w http.ResponseWriter, r *http.Request) {
 	wg.Wait()
 }
 
+// authenticateWatcher decides how much of the tree the watcher may see, which
+// is everything for those carrying the admin token. Anyone else proves who
+// they are via the key handshake, and may see as much as the tree's policy
+// permits their role. Watchers may ask for less via the `level` query
+// parameter.
+//
+// Lets the watcher know, should it not be let in
+func authenticateWatcher(c *websocket.Conn, r *http.Request, treeID string) (access.WatchLevel, bool) {
+	writer := ws.NewWriter(c)
+
+	granted := access.WatchFull
+	if !isAdmin(r) {
+		ok, clientID, err := wskeyauth.Handshake(c)
+		if err != nil {
+			fmt.Fprint(os.Stderr, err.Error())
+			return "", false
+		}
+		if !ok {
+			return "", false
+		}
+
+		role, err := policies.Admit(treeID, clientID, r.URL.Query().Get("invite"), time.Now())
+		if err != nil {
+			writeAccessError(writer, treeID, err)
+			return "", false
+		}
+		granted = policies.Get(treeID).WatchLevel(role)
+	}
+
+	if !r.URL.Query().Has("level") {
+		return granted, true
+	}
+
+	level, err := access.ParseWatchLevel(r.URL.Query().Get("level"))
+	if err != nil {
+		writer.WriteJSON(map[string]any{
+			"type": "CLIENT_ERROR",
+			"data": map[string]any{
+				"type": "INVALID_WATCH_LEVEL",
+				"data": map[string]any{
+					"message": fmt.Sprintf("Unknown watch level %s", r.URL.Query().Get("level")),
+				},
+			},
+		})
+		return "", false
+	}
+	if !granted.Includes(level) {
+		writer.WriteJSON(map[string]any{
+			"type": "CLIENT_ERROR",
+			"data": map[string]any{
+				"type": "FORBIDDEN",
+				"data": map[string]any{
+					"message": fmt.Sprintf("May only watch tree %s at the %s level", treeID, granted),
+					"meta": map[string]any{
+						"action":  "watch",
+						"level":   level,
+						"granted": granted,
+					},
+				},
+			},
+		})
+		return "", false
+	}
+	return level, true
+}
+
 func handleWatchTree(w http.ResponseWriter, r *http.Request) {
 	// This is where clients running diagnostics on a tree can peer into the state
 	// of the tree
@@ -318,34 +439,104 @@ func handleWatchTree(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeTree := func() error {
-		return c.WriteJSON(
-			map[string]interface{}{
-				"type": "TREE",
-				"data": trees.
-					GetTree(treeId).
-					AdjacencyList(),
-			},
-		)
+	level, ok := authenticateWatcher(c, r, treeId)
+	if !ok {
+		return
 	}
 
-	writeTree()
+	// Watchers that connect with the `diff` query parameter get the full tree
+	// only once, and only what has changed from then on
+	diff := r.URL.Query().Has("diff")
+
+	// Watchers that are reconnecting can supply the epoch of the tree that they
+	// last saw, and will not be sent the tree again, if it has not changed since
+	resumed, err := strconv.ParseUint(r.URL.Query().Get("epoch"), 10, 64)
+	resuming := err == nil
 
 	listener := trees.RegisterChangeListener(treeId)
 	defer trees.UnregisterChangeListener(treeId, listener)
 
+	// view gets the tree as the watcher may see it
+	view := func() (adjacencylist.AdjacencyList[string, json.RawMessage], uint64) {
+		list, epoch := trees.GetAdjacencyListAtEpoch(treeId)
+		policy := policies.Get(treeId)
+		return adjacencylist.Map(list, func(p participant) json.RawMessage {
+			return p.view(policy, level)
+		}), epoch
+	}
+
+	last, epoch := view()
+	if !resuming || resumed != epoch {
+		err := c.WriteJSON(epochMessage{Type: "TREE", Epoch: epoch, Data: last})
+		if err != nil {
+			return
+		}
+	}
+
 	for {
-		<-listener
-		if writeTree() != nil {
+		if _, ok := <-listener.Events(); !ok {
+			return
+		}
+
+		current, currentEpoch := view()
+		if currentEpoch == epoch {
+			continue
+		}
+		epoch = currentEpoch
+
+		if !diff {
+			last = current
+			if c.WriteJSON(epochMessage{Type: "TREE", Epoch: epoch, Data: current}) != nil {
+				return
+			}
+			continue
+		}
+
+		difference := adjacencylist.DiffFunc(last, current, func(a, b json.RawMessage) bool {
+			return bytes.Equal(a, b)
+		})
+		last = current
+
+		if difference.IsEmpty() {
+			continue
+		}
+
+		if c.WriteJSON(epochMessage{Type: "TREE_DIFF", Epoch: epoch, Data: difference}) != nil {
 			return
 		}
 	}
 }
 
+// logLifecycle logs the creation, emptying and destruction of every tree
+func logLifecycle() {
+	listener := trees.RegisterLifecycleListener()
+	for event := range listener.Events() {
+		fmt.Printf("Tree %s %s\n", event.TreeId, event.Kind)
+	}
+}
+
 func main() {
+	trees.SetDebug(GetTreeDebug())
+	trees.SetListenerPolicy(GetListenerPolicy())
+	trees.SetRegistryConfig(GetRegistryConfig())
+
+	functions, err := GetUpstreamAggregation()
+	if err != nil {
+		panic(err)
+	}
+	upstreamFunctions = functions
+
+	go logLifecycle()
+
 	r := mux.NewRouter()
 	r.HandleFunc("/tree/{id}", handleTree).Methods("UPGRADE")
 	r.HandleFunc("/tree/{id}/watch", handleWatchTree).Methods("UPGRADE")
+	r.HandleFunc("/admin/tree/{id}", requireAdmin(handleCreateTree)).Methods("POST")
+	r.HandleFunc("/admin/tree/{id}", requireAdmin(handleDestroyTree)).Methods("DELETE")
+	r.HandleFunc("/admin/tree/{id}/access", requireAdmin(handleGetAccess)).Methods("GET")
+	r.HandleFunc("/admin/tree/{id}/access", requireAdmin(handleSetAccess)).Methods("PUT")
+	r.HandleFunc("/admin/tree/{id}/move", requireAdmin(handleMoveNode)).Methods("POST")
+	r.HandleFunc("/admin/tree/{id}/pin", requireAdmin(handlePinNode)).Methods("POST")
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%d", GetPort()))
 	if err != nil {
 		panic(err)
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"tree/access"
+	"tree/graph/treegraph"
+)
+
+// session is a participant's connection to a tree, over which it sends
+// messages to the other participants in the tree
+type session struct {
+	treeID   string
+	clientID string
+	writer   messageWriter
+	role     access.Role
+
+	// envelope is whether the participant has opted into having the messages
+	// relayed to it wrapped in an envelope
+	envelope bool
+}
+
+// writeError lets the participant know that something went wrong with one of
+// its messages. The kind is either CLIENT_ERROR, or SERVER_ERROR
+func (s session) writeError(kind, errorType string, data map[string]any) {
+	s.writer.WriteJSON(map[string]any{
+		"type": kind,
+		"data": map[string]any{
+			"type": errorType,
+			"data": data,
+		},
+	})
+}
+
+// writeForbidden lets the participant know that its role does not permit the
+// action
+func (s session) writeForbidden(action access.Action, original json.RawMessage) {
+	s.writeError("CLIENT_ERROR", "FORBIDDEN", map[string]any{
+		"message": fmt.Sprintf("A %s may not %s", s.role, action),
+		"meta": map[string]any{
+			"action":           action,
+			"role":             s.role,
+			"original_message": original,
+		},
+	})
+}
+
+// handleMessage handles a single message from the participant
+func (s session) handleMessage(td TypeData) {
+	switch td.Type {
+	case "SET_META":
+		// Only ever updates the participant where it is, should the connection
+		// have outlived the participant's place in the tree
+		trees.Update(s.treeID, s.clientID, newParticipant(s, td.Data))
+	case "BROADCAST":
+		s.handleBroadcast(td)
+	case "SEND":
+		s.handleSend(td)
+	case "SEND_ROUTED":
+		s.handleSendRouted(td)
+	case "FLOOD":
+		s.handleFlood(td)
+	case "UPSTREAM":
+		s.handleUpstream(td)
+	case "SET_TREE_SETTINGS":
+		s.handleSetTreeSettings(td)
+	}
+}
+
+// handleBroadcast sends the message to all of the participant's neighbors, or
+// only to its children, for participants that may only relay
+func (s session) handleBroadcast(td TypeData) {
+	var recipients []treegraph.Pair[string, participant]
+	switch {
+	case s.role.Can(access.ActionBroadcast):
+		neighbors, ok := trees.GetNeighborOfNode(s.treeID, s.clientID)
+		if !ok {
+			return
+		}
+		recipients = neighbors
+	case s.role.Can(access.ActionRelay):
+		position, ok := trees.GetPositionOfNode(s.treeID, s.clientID)
+		if !ok {
+			return
+		}
+		recipients = position.Children
+	default:
+		s.writeForbidden(access.ActionBroadcast, td.Data)
+		return
+	}
+
+	e := newEnvelope(s.clientID, td)
+	for _, n := range recipients {
+		if err := n.Value.deliver(e); err != nil {
+			s.writeError("SERVER_ERROR", "UNABLE_TO_SEND_MESSAGE", map[string]any{
+				"message": fmt.Sprintf("In broadcast, error sending message to participant with client ID of %s. Could be that the participant is no longer there", n.Key),
+				"meta": map[string]any{
+					"error":            err.Error(),
+					"to":               n.Key,
+					"original_message": td.Data,
+				},
+			})
+		}
+	}
+}
+
+// handleSend sends the message to one of the participant's neighbors, or only
+// to its parent, for participants that may only send upstream
+func (s session) handleSend(td TypeData) {
+	type message struct {
+		To   string          `json:"to"`
+		Data json.RawMessage `json:"data"`
+	}
+
+	var m message
+	if err := json.Unmarshal(td.Data, &m); err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"title":   "Message intended for participant was malformed",
+			"message": fmt.Sprintf("Error parsing the message that was intended for participant %s", m.To),
+			"meta": map[string]any{
+				"error": err.Error(),
+				"to":    m.To,
+			},
+		})
+		return
+	}
+
+	if !s.role.Can(access.ActionSend) && !s.maySendUpstream(m.To) {
+		s.writeForbidden(access.ActionSend, td.Data)
+		return
+	}
+
+	neighbors, ok := trees.GetNeighborOfNode(s.treeID, s.clientID)
+	if !ok {
+		return
+	}
+
+	for _, n := range neighbors {
+		if n.Key != m.To {
+			continue
+		}
+
+		if err := n.Value.deliver(newEnvelope(s.clientID, TypeData{td.Type, m.Data})); err != nil {
+			s.writeError("SERVER_ERROR", "UNABLE_TO_SEND_MESSAGE", map[string]any{
+				"message": fmt.Sprintf("Error sending message to participant with client ID of %s. Could be that the participant is no longer there", n.Key),
+				"meta": map[string]any{
+					"error":            err.Error(),
+					"to":               m.To,
+					"original_message": td.Data,
+				},
+			})
+		}
+		return
+	}
+
+	s.writeError("CLIENT_ERROR", "PARTICIPANT_NOT_FOUND", map[string]any{
+		"message": fmt.Sprintf("Participant with ID %s not found", m.To),
+		"meta": map[string]any{
+			"to":               m.To,
+			"original_message": td.Data,
+		},
+	})
+}
+
+// maySendUpstream determines whether the participant may send upstream, to the
+// participant with the supplied ID as its parent
+func (s session) maySendUpstream(clientID string) bool {
+	if !s.role.Can(access.ActionSendUpstream) {
+		return false
+	}
+	position, ok := trees.GetPositionOfNode(s.treeID, s.clientID)
+	if !ok {
+		return false
+	}
+	parent, ok := position.Parent.Get()
+	return ok && parent.Key == clientID
+}
+
+// handleSetTreeSettings edits the settings of the tree. Only the settings that
+// are present in the message are changed
+func (s session) handleSetTreeSettings(td TypeData) {
+	if !s.role.Can(access.ActionEditTree) {
+		s.writeForbidden(access.ActionEditTree, td.Data)
+		return
+	}
+
+	var settings treeSettings
+	if err := json.Unmarshal(td.Data, &settings); err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": "Error parsing the settings of the tree",
+			"meta": map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+
+	err := trees.UpdateTreeConfig(s.treeID, settings.apply)
+	if err != nil {
+		writeTreeNotFound(s.writer, s.treeID)
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"sync"
+	"testing"
+	"time"
+
+	"tree/access"
+	"tree/graph/treegraph"
+)
+
+// fakeWriter stands in for the connection of a client, and keeps everything
+// written to it
+type fakeWriter struct {
+	mut      sync.Mutex
+	messages []json.RawMessage
+
+	// err, if set, fails every write, as a connection that is gone would
+	err error
+}
+
+func (w *fakeWriter) WriteJSON(v any) error {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+
+	if w.err != nil {
+		return w.err
+	}
+	encoded, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	w.messages = append(w.messages, encoded)
+	return nil
+}
+
+// all gets everything written to the client, exactly as it was written
+func (w *fakeWriter) all() []json.RawMessage {
+	w.mut.Lock()
+	defer w.mut.Unlock()
+	return append([]json.RawMessage{}, w.messages...)
+}
+
+// received gets the data of every message of the supplied type written to the
+// client
+func (w *fakeWriter) received(messageType string) []json.RawMessage {
+	data := []json.RawMessage{}
+	for _, message := range w.all() {
+		var td TypeData
+		if json.Unmarshal(message, &td) == nil && td.Type == messageType {
+			data = append(data, td.Data)
+		}
+	}
+	return data
+}
+
+// await waits for the client to have been written the supplied number of
+// messages of the supplied type, for messages that are written from goroutines
+// of their own
+func (w *fakeWriter) await(t *testing.T, messageType string, n int) []json.RawMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		received := w.received(messageType)
+		if len(received) >= n {
+			return received
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d %s messages, but got %d", n, messageType, len(received))
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// newTestTree creates a tree of its own for the test, with the supplied config,
+// which is destroyed once the test is over
+func newTestTree(t *testing.T, config treegraph.Config) string {
+	t.Helper()
+
+	treeID := t.Name()
+	if err := trees.CreateTree(treeID, config); err != nil {
+		t.Fatalf("Expected tree %s to be created, but got %v", treeID, err)
+	}
+	t.Cleanup(func() { trees.DestroyTree(treeID) })
+	return treeID
+}
+
+// newChain creates a tree in which every participant has a single child, so
+// that participants are placed one after the other, in the order in which
+// they join
+func newChain(t *testing.T) string {
+	return newTestTree(t, treegraph.Config{MaxDegree: 2, RootDegree: 1})
+}
+
+// joinTree has the client join the tree, as if it had connected with the
+// supplied role, and gets the client's session, along with its connection
+func joinTree(
+	t *testing.T,
+	treeID, clientID string,
+	role access.Role,
+	envelope bool,
+) (session, *fakeWriter) {
+	t.Helper()
+
+	w := &fakeWriter{}
+	s := session{treeID, clientID, w, role, envelope}
+	change, err := trees.Join(treeID, clientID, newParticipant(s, json.RawMessage(`{}`)), trees.DefaultConfig())
+	if err != nil || change.Waitlisted > 0 {
+		t.Fatalf("Expected %s to have joined tree %s, but got %v", clientID, treeID, err)
+	}
+	return s, w
+}
+
+// message creates a message, as a client would send it
+func message(messageType, data string) TypeData {
+	return TypeData{messageType, json.RawMessage(data)}
+}
+
+// errorType gets the type of the error in the data of a CLIENT_ERROR or
+// SERVER_ERROR message
+func errorType(data json.RawMessage) string {
+	var e struct {
+		Type string `json:"type"`
+	}
+	json.Unmarshal(data, &e)
+	return e.Type
+}
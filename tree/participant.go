@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"tree/access"
+	"tree/graph/maybe"
+	"tree/graph/treegraph"
+)
+
+// messageWriter is what messages get written to a client through, which is a
+// ws.Writer for any client connected over a WebSocket
+type messageWriter interface {
+	WriteJSON(v any) error
+}
+
+// TODO: Gotta find a better name for this.
+type participant struct {
+	writer   messageWriter
+	meta     json.RawMessage
+	capacity treegraph.Capacity
+	role     access.Role
+	envelope bool
+}
+
+var _ json.Marshaler = participant{}
+var _ treegraph.CapacityDeclarer = participant{}
+
+// newParticipant creates a participant, with the capacity extracted from the
+// well-known `capacity` field of the supplied metadata.
+//
+// The capacity can be declared either as a number of children, or as an upload
+// bandwidth in bits per second:
+//
+//	{ "capacity": { "children": 2 } }
+//	{ "capacity": { "bandwidth": 5000000 } }
+//
+// Viewers never relay, and so are always declared to have room for no children,
+// whatever their metadata says
+func newParticipant(s session, meta json.RawMessage) participant {
+	var m struct {
+		Capacity struct {
+			Children  *int `json:"children"`
+			Bandwidth *int `json:"bandwidth"`
+		} `json:"capacity"`
+	}
+
+	capacity := treegraph.Capacity{
+		Children:  maybe.Nothing[int](),
+		Bandwidth: maybe.Nothing[int](),
+	}
+
+	// Metadata is free-form, so anything that does not fit the shape above is
+	// simply treated as not having declared a capacity
+	if json.Unmarshal(meta, &m) == nil {
+		if m.Capacity.Children != nil {
+			capacity.Children = maybe.Something(*m.Capacity.Children)
+		}
+		if m.Capacity.Bandwidth != nil {
+			capacity.Bandwidth = maybe.Something(*m.Capacity.Bandwidth)
+		}
+	}
+
+	if !s.role.Can(access.ActionRelay) {
+		capacity.Children = maybe.Something(0)
+	}
+
+	return participant{s.writer, meta, capacity, s.role, s.envelope}
+}
+
+// MarshalJSON has a value receiver, so that participants held by value, such as
+// in an adjacency list, do not get marshaled as an empty object
+func (p participant) MarshalJSON() ([]byte, error) {
+	return p.meta, nil
+}
+
+// view gets the metadata of the participant, as it may be seen at the supplied
+// watch level of the tree with the supplied policy
+func (p participant) view(policy access.Policy, level access.WatchLevel) json.RawMessage {
+	switch {
+	case level.Includes(access.WatchFull):
+		return p.meta
+	case level.Includes(access.WatchPublic):
+		return policy.Redact(p.meta)
+	default:
+		return json.RawMessage("null")
+	}
+}
+
+// viewPairs gets the metadata of every participant, as it may be seen at the
+// supplied watch level of the tree with the supplied policy
+func viewPairs(
+	pairs []treegraph.Pair[string, participant],
+	policy access.Policy,
+	level access.WatchLevel,
+) []treegraph.Pair[string, json.RawMessage] {
+	viewed := make([]treegraph.Pair[string, json.RawMessage], len(pairs))
+	for i, pair := range pairs {
+		viewed[i] = treegraph.Pair[string, json.RawMessage]{
+			Key:       pair.Key,
+			Value:     pair.Value.view(policy, level),
+			Suspended: pair.Suspended,
+		}
+	}
+	return viewed
+}
+
+func (p participant) DeclaredCapacity() treegraph.Capacity {
+	return p.capacity
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+
+	"tree/access"
+	"tree/graph/treegraph"
+	"tree/graph/treemanager"
+)
+
+func TestViewPairs(t *testing.T) {
+	pairs := []treegraph.Pair[string, participant]{
+		{Key: "a", Value: participant{meta: []byte(`{"name":"a","email":"a@example.com"}`)}},
+		{Key: "b", Value: participant{meta: []byte(`{"name":"b"}`)}, Suspended: true},
+	}
+	policy := access.Policy{Private: []string{"email"}}
+
+	viewed := viewPairs(pairs, policy, access.WatchPublic)
+	if string(viewed[0].Value) != `{"name":"a"}` {
+		t.Errorf("Expected the private fields to have been stripped, but got %s", viewed[0].Value)
+	}
+	if viewed[0].Suspended || !viewed[1].Suspended {
+		t.Errorf("Expected redaction to keep whether nodes are suspended, but got %v", viewed)
+	}
+
+	for _, pair := range viewPairs(pairs, policy, access.WatchTopology) {
+		if string(pair.Value) != "null" {
+			t.Errorf("Expected no metadata at the topology level, but got %s", pair.Value)
+		}
+	}
+}
+
+func TestViewersNeverRelay(t *testing.T) {
+	manager := treemanager.NewTreeManager[string, participant](treegraph.Config{MaxDegree: 4, RootDegree: 1})
+	config := manager.DefaultConfig()
+
+	join := func(clientID string, role access.Role) treemanager.Change[string, participant] {
+		s := session{treeID: "tree", clientID: clientID, role: role}
+		change, err := manager.Join("tree", clientID, newParticipant(s, []byte(`{"capacity":{"children":3}}`)), config)
+		if err != nil {
+			t.Fatalf("Expected %s to be able to join, but got %v", clientID, err)
+		}
+		return change
+	}
+
+	join("publisher", access.RolePublisher)
+	join("viewer1", access.RoleViewer)
+
+	// The publisher's only seat is taken by a viewer, which declared room for
+	// children, but is not permitted to relay
+	change := join("viewer2", access.RoleViewer)
+	if change.Waitlisted != 1 {
+		t.Errorf("Expected viewer2 to have been waitlisted, but got %d", change.Waitlisted)
+	}
+	position, _ := manager.GetPositionOfNode("tree", "viewer1")
+	if len(position.Children) != 0 {
+		t.Errorf("Expected viewer1 to have no children, but got %v", position.Children)
+	}
+
+	// A relay that joins later waits its turn, rather than going under a viewer
+	if change := join("relay", access.RoleRelay); change.Waitlisted != 2 {
+		t.Errorf("Expected the relay to have been waitlisted, but got %d", change.Waitlisted)
+	}
+}
+
+func TestSetMetaOnlyUpdates(t *testing.T) {
+	treeID := newChain(t)
+	a, _ := joinTree(t, treeID, "a", access.RolePublisher, false)
+	joinTree(t, treeID, "b", access.RolePublisher, false)
+
+	a.handleMessage(message("SET_META", `{"name":"a"}`))
+	position, _ := trees.GetPositionOfNode(treeID, "b")
+	if parent, _ := position.Parent.Get(); string(parent.Value.meta) != `{"name":"a"}` {
+		t.Errorf("Expected a to have been updated, but got %s", parent.Value.meta)
+	}
+
+	// A connection that outlived the participant's place in the tree is unable
+	// to put it back
+	trees.DeleteNode(treeID, "a")
+	a.handleMessage(message("SET_META", `{"name":"a"}`))
+	if tree, _ := trees.GetTree(treeID); tree.Has("a") {
+		t.Error("Expected a to not have been put back in the tree")
+	}
+
+	// Neither is it able to bring back a tree that has since been destroyed
+	trees.DestroyTree(treeID)
+	a.handleMessage(message("SET_META", `{"name":"a"}`))
+	if _, ok := trees.GetTree(treeID); ok {
+		t.Error("Expected the tree to not have been created again")
+	}
+}
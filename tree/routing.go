@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"tree/access"
+)
+
+const (
+	// routeRelay has the server send a copy of a routed message to every hop
+	// along the path, one after the other, as though it were handed from one
+	// hop to the next. The hops themselves take no part in forwarding it
+	routeRelay = "relay"
+
+	// routeDirect has a routed message delivered straight to its target
+	routeDirect = "direct"
+)
+
+// routedMessage is what everyone that a routed message is delivered to is sent
+type routedMessage struct {
+	envelope
+
+	// To is the client ID of the participant that the message is for. Hops along
+	// the way are able to tell that it is not for them
+	To string `json:"to"`
+
+	// Mode is how the message is routed. See the route* constants
+	Mode string `json:"mode"`
+
+	// Path is the client IDs of everyone along the path of the message, from
+	// the sender to the target, both of them included
+	Path []string `json:"path"`
+}
+
+// routeReport lets the sender of a routed message know how far it got
+type routeReport struct {
+	ID        string   `json:"id"`
+	To        string   `json:"to"`
+	Mode      string   `json:"mode"`
+	Path      []string `json:"path"`
+	Delivered bool     `json:"delivered"`
+
+	// FailedAt is the client ID of the hop that the message could not be
+	// delivered to, if any
+	FailedAt string `json:"failedAt,omitempty"`
+}
+
+// handleSendRouted delivers the message to anyone in the tree, either by
+// writing it to every hop along the path through the tree in turn, or straight
+// to the target. Either way, it is the server that does all of the writing.
+// Participants that may only send upstream may only route to their ancestors
+func (s session) handleSendRouted(td TypeData) {
+	var m struct {
+		To   string          `json:"to"`
+		Mode string          `json:"mode"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(td.Data, &m); err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": "Error parsing the message that was to be routed",
+			"meta": map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+	if m.Mode == "" {
+		m.Mode = routeRelay
+	}
+	if m.Mode != routeRelay && m.Mode != routeDirect {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": fmt.Sprintf("Unknown routing mode %s", m.Mode),
+			"meta": map[string]any{
+				"mode": m.Mode,
+			},
+		})
+		return
+	}
+
+	if !s.role.Can(access.ActionSendRouted) &&
+		!(s.role.Can(access.ActionSendUpstream) && trees.IsAncestor(s.treeID, m.To, s.clientID)) {
+		s.writeForbidden(access.ActionSendRouted, td.Data)
+		return
+	}
+
+	path, ok := trees.GetPath(s.treeID, s.clientID, m.To)
+	if !ok || len(path) < 2 {
+		s.writeError("CLIENT_ERROR", "PARTICIPANT_NOT_FOUND", map[string]any{
+			"message": fmt.Sprintf("Participant with ID %s not found", m.To),
+			"meta": map[string]any{
+				"to":               m.To,
+				"original_message": td.Data,
+			},
+		})
+		return
+	}
+
+	message := routedMessage{
+		envelope: newEnvelope(s.clientID, TypeData{td.Type, m.Data}),
+		To:       m.To,
+		Mode:     m.Mode,
+	}
+	for _, hop := range path {
+		message.Path = append(message.Path, hop.Key)
+	}
+
+	hops := path[1:]
+	if m.Mode == routeDirect {
+		hops = path[len(path)-1:]
+		message.Path = []string{s.clientID, m.To}
+	}
+
+	// Stopping at the first hop that cannot be written to mimics a message that
+	// is lost along the way, even though the hops beyond are reachable
+	report := routeReport{ID: message.ID, To: m.To, Mode: m.Mode, Path: message.Path, Delivered: true}
+	for _, hop := range hops {
+		err := hop.Value.writer.WriteJSON(map[string]any{
+			"type": "ROUTED",
+			"data": message,
+		})
+		if err != nil {
+			report.Delivered = false
+			report.FailedAt = hop.Key
+			break
+		}
+	}
+
+	s.writer.WriteJSON(map[string]any{
+		"type": "ROUTE_REPORT",
+		"data": report,
+	})
+}
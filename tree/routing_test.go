@@ -0,0 +1,101 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"tree/access"
+	"tree/graph/treegraph"
+)
+
+// routeReportOf gets the last ROUTE_REPORT written to the client
+func routeReportOf(t *testing.T, w *fakeWriter) routeReport {
+	t.Helper()
+
+	reports := w.received("ROUTE_REPORT")
+	if len(reports) <= 0 {
+		t.Fatalf("Expected a route report, but got %s", w.all())
+	}
+	var report routeReport
+	if err := json.Unmarshal(reports[len(reports)-1], &report); err != nil {
+		t.Fatal(err)
+	}
+	return report
+}
+
+func TestSendRouted(t *testing.T) {
+	treeID := newChain(t)
+	a, _ := joinTree(t, treeID, "a", access.RolePublisher, false)
+	_, b := joinTree(t, treeID, "b", access.RoleRelay, false)
+	_, c := joinTree(t, treeID, "c", access.RoleRelay, false)
+	_, d := joinTree(t, treeID, "d", access.RoleRelay, false)
+
+	// Relayed messages are seen by every hop along the way
+	a.handleMessage(message("SEND_ROUTED", `{"to":"d","data":"hi"}`))
+	report := routeReportOf(t, a.writer.(*fakeWriter))
+	if !report.Delivered || report.FailedAt != "" || report.Mode != routeRelay {
+		t.Errorf("Expected the message to have been relayed all the way, but got %+v", report)
+	}
+	if !slices.Equal(report.Path, []string{"a", "b", "c", "d"}) {
+		t.Errorf("Expected the path to go through b and c, but got %v", report.Path)
+	}
+	for _, hop := range []*fakeWriter{b, c, d} {
+		var routed routedMessage
+		received := hop.received("ROUTED")
+		if len(received) != 1 || json.Unmarshal(received[0], &routed) != nil {
+			t.Fatalf("Expected every hop to have been sent the message, but got %s", hop.all())
+		}
+		if routed.ID != report.ID || routed.To != "d" || routed.From != "a" {
+			t.Errorf("Expected the message from a to d, but got %+v", routed)
+		}
+	}
+
+	// Direct messages skip the hops in between
+	a.handleMessage(message("SEND_ROUTED", `{"to":"d","mode":"direct","data":"hi"}`))
+	report = routeReportOf(t, a.writer.(*fakeWriter))
+	if !report.Delivered || !slices.Equal(report.Path, []string{"a", "d"}) {
+		t.Errorf("Expected the message to have gone straight to d, but got %+v", report)
+	}
+	if len(b.received("ROUTED")) != 1 || len(d.received("ROUTED")) != 2 {
+		t.Error("Expected only d to have been sent the direct message")
+	}
+
+	// A hop that cannot be reached stops the message there
+	c.err = errors.New("connection closed")
+	a.handleMessage(message("SEND_ROUTED", `{"to":"d","data":"hi"}`))
+	report = routeReportOf(t, a.writer.(*fakeWriter))
+	if report.Delivered || report.FailedAt != "c" {
+		t.Errorf("Expected the message to have failed at c, but got %+v", report)
+	}
+	if len(d.received("ROUTED")) != 2 {
+		t.Error("Expected the message to have gone no further than c")
+	}
+}
+
+func TestSendRoutedUpstreamOnly(t *testing.T) {
+	treeID := newTestTree(t, treegraph.Config{MaxDegree: 3, RootDegree: 2})
+	_, a := joinTree(t, treeID, "a", access.RolePublisher, false)
+	b, _ := joinTree(t, treeID, "b", access.RoleViewer, false)
+	_, c := joinTree(t, treeID, "c", access.RoleViewer, false)
+
+	// Viewers may route to their ancestors
+	b.handleMessage(message("SEND_ROUTED", `{"to":"a","data":"hi"}`))
+	if report := routeReportOf(t, b.writer.(*fakeWriter)); !report.Delivered {
+		t.Errorf("Expected the viewer to have reached its parent, but got %+v", report)
+	}
+	if len(a.received("ROUTED")) != 1 {
+		t.Errorf("Expected a to have been sent the message, but got %s", a.all())
+	}
+
+	// But not to anyone else, such as a sibling
+	b.handleMessage(message("SEND_ROUTED", `{"to":"c","data":"hi"}`))
+	errs := b.writer.(*fakeWriter).received("CLIENT_ERROR")
+	if len(errs) != 1 || errorType(errs[0]) != "FORBIDDEN" {
+		t.Errorf("Expected the viewer to have been forbidden from routing to c, but got %s", errs)
+	}
+	if len(c.all()) != 0 {
+		t.Errorf("Expected c to not have been sent anything, but got %s", c.all())
+	}
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"tree/access"
+	"tree/aggregate"
+)
+
+// upstreamKey identifies the window in which everything of a kind that is sent
+// upstream to a participant is merged
+type upstreamKey struct {
+	treeID string
+	nodeID string
+	kind   string
+}
+
+// upstreamFunctions are the kinds of messages sent upstream that get
+// aggregated, along with the function that each of them is aggregated with
+var upstreamFunctions map[string]aggregate.Function
+
+// upstream merges what is sent upstream to every participant, until its window
+// is over
+var upstream aggregate.Windows[upstreamKey]
+
+// The windows are set up right away, rather than in main, so that they are
+// ready for any session, including those of tests. They cannot be set up
+// where they are declared, since flushUpstream adds to them
+func init() {
+	upstream = aggregate.NewWindows(GetUpstreamWindow(), flushUpstream)
+}
+
+// upstreamMessage is what everyone upstream of the sender of a message of a
+// kind that does not get aggregated is sent
+type upstreamMessage struct {
+	envelope
+
+	// Kind is the kind of the message, as chosen by the sender
+	Kind string `json:"kind"`
+
+	// Hops is how many hops downstream of the receiver the sender is
+	Hops int `json:"hops"`
+}
+
+// aggregatedMessage is what a participant is sent once a window of everything
+// of a kind that was sent upstream to it is over
+type aggregatedMessage struct {
+	Kind      string             `json:"kind"`
+	Aggregate aggregate.Function `json:"aggregate"`
+	Value     any                `json:"value"`
+
+	// Count is the number of messages that the value is made up of
+	Count int `json:"count"`
+
+	// Timestamp is when the window was over, in milliseconds since the Unix
+	// epoch
+	Timestamp int64 `json:"timestamp"`
+}
+
+// handleUpstream sends the message toward the root, hop by hop. Messages of a
+// kind that gets aggregated are merged with everything else of the same kind
+// at every hop, and handed on once the window of the hop is over. Anything
+// else is delivered to every hop right away
+func (s session) handleUpstream(td TypeData) {
+	var m struct {
+		Kind string          `json:"kind"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(td.Data, &m); err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": "Error parsing the message that was to be sent upstream",
+			"meta": map[string]any{
+				"error": err.Error(),
+			},
+		})
+		return
+	}
+
+	if !s.role.Can(access.ActionSendUpstream) {
+		s.writeForbidden(access.ActionSendUpstream, td.Data)
+		return
+	}
+
+	path, ok := trees.GetPathToRoot(s.treeID, s.clientID)
+	if !ok || len(path) < 2 {
+		return
+	}
+
+	function, ok := upstreamFunctions[m.Kind]
+	if !ok {
+		e := newEnvelope(s.clientID, TypeData{td.Type, m.Data})
+		for i, hop := range path[1:] {
+			err := hop.Value.writer.WriteJSON(map[string]any{
+				"type": "UPSTREAM",
+				"data": upstreamMessage{e, m.Kind, i + 1},
+			})
+			if err != nil {
+				s.writeError("SERVER_ERROR", "UNABLE_TO_SEND_MESSAGE", map[string]any{
+					"message": fmt.Sprintf("Upstream, error sending message to participant with client ID of %s. Could be that the participant is no longer there", hop.Key),
+					"meta": map[string]any{
+						"error":            err.Error(),
+						"to":               hop.Key,
+						"original_message": td.Data,
+					},
+				})
+			}
+		}
+		return
+	}
+
+	a, err := function.Lift(m.Data)
+	if err != nil {
+		s.writeError("CLIENT_ERROR", "MALFORMED_MESSAGE", map[string]any{
+			"message": fmt.Sprintf("Messages of kind %s are aggregated by %s, which is unable to aggregate the payload", m.Kind, function),
+			"meta": map[string]any{
+				"error":            err.Error(),
+				"kind":             m.Kind,
+				"aggregate":        function,
+				"original_message": td.Data,
+			},
+		})
+		return
+	}
+
+	upstream.Add(upstreamKey{s.treeID, path[1].Key, m.Kind}, a)
+}
+
+// flushUpstream delivers what was aggregated within a window to the
+// participant, and hands it on to the participant's parent. What was meant for
+// participants that have since left the tree is dropped
+func flushUpstream(key upstreamKey, a aggregate.Aggregate) {
+	path, ok := trees.GetPathToRoot(key.treeID, key.nodeID)
+	if !ok {
+		return
+	}
+
+	// A participant that cannot be reached does not keep what was aggregated
+	// from those upstream of it, since it is the server that does the relaying.
+	// The participant's writer takes turns with the participant's own session,
+	// so this is safe to write from the window's goroutine
+	path[0].Value.writer.WriteJSON(map[string]any{
+		"type": "UPSTREAM",
+		"data": aggregatedMessage{
+			Kind:      key.kind,
+			Aggregate: a.Function(),
+			Value:     a.Value(),
+			Count:     a.Count(),
+			Timestamp: time.Now().UnixMilli(),
+		},
+	})
+
+	if len(path) > 1 {
+		upstream.Add(upstreamKey{key.treeID, path[1].Key, key.kind}, a)
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"tree/access"
+	"tree/aggregate"
+)
+
+// aggregateUpstream has the supplied kinds aggregated for the duration of the
+// test, over windows of the supplied length
+func aggregateUpstream(t *testing.T, window time.Duration, functions map[string]aggregate.Function) {
+	previousFunctions, previous := upstreamFunctions, upstream
+	upstreamFunctions = functions
+	upstream = aggregate.NewWindows(window, flushUpstream)
+	t.Cleanup(func() {
+		upstreamFunctions, upstream = previousFunctions, previous
+	})
+}
+
+func TestUpstream(t *testing.T) {
+	aggregateUpstream(t, 10*time.Millisecond, map[string]aggregate.Function{"reactions": aggregate.Sum})
+
+	treeID := newChain(t)
+	_, a := joinTree(t, treeID, "a", access.RolePublisher, false)
+	_, b := joinTree(t, treeID, "b", access.RoleRelay, false)
+	c, _ := joinTree(t, treeID, "c", access.RoleViewer, false)
+
+	// Kinds that are not aggregated are delivered to every hop right away
+	c.handleMessage(message("UPSTREAM", `{"kind":"hello","data":"hi"}`))
+	for hops, w := range []*fakeWriter{b, a} {
+		var m upstreamMessage
+		received := w.received("UPSTREAM")
+		if len(received) != 1 || json.Unmarshal(received[0], &m) != nil {
+			t.Fatalf("Expected every hop to have been sent the message, but got %s", w.all())
+		}
+		if m.Kind != "hello" || m.From != "c" || m.Hops != hops+1 || string(m.Data) != `"hi"` {
+			t.Errorf("Expected the message of c, %d hops down, but got %+v", hops+1, m)
+		}
+	}
+
+	// Kinds that are aggregated are merged at every hop, and handed on once the
+	// window of the hop is over
+	c.handleMessage(message("UPSTREAM", `{"kind":"reactions","data":2}`))
+	c.handleMessage(message("UPSTREAM", `{"kind":"reactions","data":3}`))
+	for _, w := range []*fakeWriter{b, a} {
+		var m aggregatedMessage
+		received := w.await(t, "UPSTREAM", 2)
+		if json.Unmarshal(received[1], &m) != nil {
+			t.Fatalf("Expected an aggregate, but got %s", received[1])
+		}
+		if m.Kind != "reactions" || m.Aggregate != aggregate.Sum || m.Value != 5.0 || m.Count != 2 {
+			t.Errorf("Expected the sum of both reactions, but got %+v", m)
+		}
+	}
+
+	// Payloads that cannot be aggregated are rejected
+	c.handleMessage(message("UPSTREAM", `{"kind":"reactions","data":"lots"}`))
+	errs := c.writer.(*fakeWriter).received("CLIENT_ERROR")
+	if len(errs) != 1 || errorType(errs[0]) != "MALFORMED_MESSAGE" {
+		t.Errorf("Expected the payload to have been rejected, but got %s", errs)
+	}
+}
+
+func TestUpstreamWindowsReady(t *testing.T) {
+	// Only the kinds are swapped out, leaving the windows as they were set up
+	// for the package
+	previous := upstreamFunctions
+	upstreamFunctions = map[string]aggregate.Function{"reactions": aggregate.Sum}
+	t.Cleanup(func() { upstreamFunctions = previous })
+
+	treeID := newChain(t)
+	joinTree(t, treeID, "a", access.RolePublisher, false)
+	b, _ := joinTree(t, treeID, "b", access.RoleViewer, false)
+
+	b.handleMessage(message("UPSTREAM", `{"kind":"reactions","data":1}`))
+	if received := b.writer.(*fakeWriter).all(); len(received) != 0 {
+		t.Errorf("Expected the reaction to have been aggregated, but got %s", received)
+	}
+}
@@ -1,6 +1,7 @@
 package ws
 
 import (
+	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -17,12 +18,16 @@ const (
 	PingPeriod = (PongWait * 9) / 10
 )
 
+// Writer writes to the connection one message at a time, since a connection
+// supports no more than one concurrent writer. Copies of a writer share its
+// lock, so every write to the connection must go through the same writer
 type Writer struct {
 	conn *websocket.Conn
+	mut  *sync.Mutex
 }
 
 func NewWriter(conn *websocket.Conn) Writer {
-	return Writer{conn: conn}
+	return Writer{conn: conn, mut: &sync.Mutex{}}
 }
 
 func (c Writer) writeDeadLine() error {
@@ -31,10 +36,20 @@ func (c Writer) writeDeadLine() error {
 }
 
 func (c Writer) WriteJSON(v any) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	err := c.writeDeadLine()
+	if err != nil {
+		return err
+	}
 	return c.conn.WriteJSON(v)
 }
 
 func (c Writer) WriteMessage(messageType int, data []byte) error {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
 	err := c.writeDeadLine()
 	if err != nil {
 		return err